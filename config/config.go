@@ -5,6 +5,14 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
 	"github.com/rs/zerolog/log"
 )
@@ -13,11 +21,78 @@ import (
 // through environment variables. Learn more through the documentation of the envconfig package.
 // https://github.com/kelseyhightower/envconfig
 type Specification struct {
-	ServiceToken                       string   `json:"serviceToken" split_words:"true" required:"true"`
-	ApiBaseUrl                         string   `json:"apiBaseUrl" split_words:"true" required:"true"`
-	DiscoveryAttributesExcludesService []string `json:"discoveryAttributesExcludesService" split_words:"true" required:"false"`
+	ServiceToken                           string         `json:"serviceToken" split_words:"true" required:"true"`
+	ApiBaseUrl                             string         `json:"apiBaseUrl" split_words:"true" required:"true"`
+	DiscoveryAttributesExcludesService     []string       `json:"discoveryAttributesExcludesService" split_words:"true" required:"false"`
+	DiscoveryAttributesExcludesDeployment  []string       `json:"discoveryAttributesExcludesDeployment" split_words:"true" required:"false"`
+	DiscoveryAttributesExcludesStatefulSet []string       `json:"discoveryAttributesExcludesStatefulSet" split_words:"true" required:"false"`
+	DiscoveryAttributesExcludesDaemonSet   []string       `json:"discoveryAttributesExcludesDaemonSet" split_words:"true" required:"false"`
+	DiscoveryAttributesExcludesPod         []string       `json:"discoveryAttributesExcludesPod" split_words:"true" required:"false"`
+	DiscoveryAttributesExcludesMonitor     []string       `json:"discoveryAttributesExcludesMonitor" split_words:"true" required:"false"`
+	ComponentTypes                         ComponentTypes `json:"componentTypes" split_words:"true" required:"false"`
+	// DiscoveryStalenessLimit is how long the last discovered targets are kept while StackState discovery fails.
+	DiscoveryStalenessLimit time.Duration `json:"discoveryStalenessLimit" split_words:"true" required:"false" default:"15m"`
+	// DiscoveryInterval is the refresh interval of all discoveries.
+	DiscoveryInterval time.Duration `json:"discoveryInterval" split_words:"true" required:"false" default:"1m"`
+	// DiscoveryFilter is an STQL filter ANDed to the service and workload discovery queries, e.g. label in ("env:prod").
+	DiscoveryFilter string `json:"discoveryFilter" split_words:"true" required:"false"`
+	// DiscoveryIncludeClusters, DiscoveryExcludeClusters, DiscoveryIncludeNamespaces and DiscoveryExcludeNamespaces
+	// limit the service and workload discovery to clusters and namespaces. Empty includes include everything.
+	DiscoveryIncludeClusters   NamePatterns `json:"discoveryIncludeClusters" split_words:"true" required:"false"`
+	DiscoveryExcludeClusters   NamePatterns `json:"discoveryExcludeClusters" split_words:"true" required:"false"`
+	DiscoveryIncludeNamespaces NamePatterns `json:"discoveryIncludeNamespaces" split_words:"true" required:"false"`
+	DiscoveryExcludeNamespaces NamePatterns `json:"discoveryExcludeNamespaces" split_words:"true" required:"false"`
+	// DiscoveryPartitioning splits the service and workload discovery into one query per cluster or namespace.
+	DiscoveryPartitioning string `json:"discoveryPartitioning" split_words:"true" required:"false"`
+	// DiscoveryConcurrency limits the number of partition queries running in parallel.
+	DiscoveryConcurrency int `json:"discoveryConcurrency" split_words:"true" required:"false" default:"4"`
+	// DiscoveryServiceRelations fetches the indirect relations between the services to add the upstream and downstream
+	// services as attributes. It is opt-in, because StackState has to compute the relations for every service.
+	DiscoveryServiceRelations bool `json:"discoveryServiceRelations" split_words:"true" required:"false"`
+	// ApiRetryCount is the number of retries of StackState API calls failing with a network error, 429 or 5xx.
+	ApiRetryCount       int           `json:"apiRetryCount" split_words:"true" required:"false" default:"3"`
+	ApiRetryWaitTime    time.Duration `json:"apiRetryWaitTime" split_words:"true" required:"false" default:"500ms"`
+	ApiRetryMaxWaitTime time.Duration `json:"apiRetryMaxWaitTime" split_words:"true" required:"false" default:"10s"`
+	// ApiCircuitBreakerThreshold is the number of consecutive StackState API calls still failing after their retries,
+	// which open the circuit breaker, 0 disables it.
+	ApiCircuitBreakerThreshold    int           `json:"apiCircuitBreakerThreshold" split_words:"true" required:"false" default:"5"`
+	ApiCircuitBreakerOpenDuration time.Duration `json:"apiCircuitBreakerOpenDuration" split_words:"true" required:"false" default:"30s"`
+}
+
+// ComponentType is a user-defined target type whose targets are the StackState components matching an STQL query.
+type ComponentType struct {
+	// Id is appended to "com.steadybit.extension_stackstate." to form the target type.
+	Id          string `json:"id"`
+	Label       string `json:"label"`
+	LabelPlural string `json:"labelPlural"`
+	Query       string `json:"query"`
+	// Attributes maps target attribute names to the component field providing the value, see ComponentFields.
+	Attributes map[string]string `json:"attributes"`
+	// AttributesExcludes lists the attributes excluded during discovery, checked by key equality and supporting a
+	// trailing "*".
+	AttributesExcludes []string `json:"attributesExcludes"`
+}
+
+// ComponentTypes is parsed from a JSON array.
+type ComponentTypes []ComponentType
+
+func (c *ComponentTypes) Decode(value string) error {
+	return json.Unmarshal([]byte(value), c)
 }
 
+// ComponentFields are the component fields that can be mapped to target attributes of a ComponentType.
+var ComponentFields = []string{"id", "name", "identifiers", "healthState", "clusterName", "namespace"}
+
+// builtInComponentTypeIds are the ids already used by the target types of the extension.
+var builtInComponentTypeIds = []string{"service", "deployment", "statefulset", "daemonset", "pod", "monitor"}
+
+var componentTypeIdPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+const (
+	DiscoveryPartitioningCluster   = "cluster"
+	DiscoveryPartitioningNamespace = "namespace"
+)
+
 var (
 	Config Specification
 )
@@ -30,5 +105,124 @@ func ParseConfiguration() {
 }
 
 func ValidateConfiguration() {
-	// You may optionally validate the configuration here.
+	if Config.DiscoveryInterval <= 0 {
+		log.Fatal().Msgf("Discovery interval must be positive, got %s.", Config.DiscoveryInterval)
+	}
+	if !isBalancedStql(Config.DiscoveryFilter) {
+		log.Fatal().Msgf("Discovery filter '%s' has unbalanced parentheses or quotes.", Config.DiscoveryFilter)
+	}
+	if Config.DiscoveryPartitioning != "" && Config.DiscoveryPartitioning != DiscoveryPartitioningCluster && Config.DiscoveryPartitioning != DiscoveryPartitioningNamespace {
+		log.Fatal().Msgf("Discovery partitioning '%s' is not supported, use '%s' or '%s'.", Config.DiscoveryPartitioning, DiscoveryPartitioningCluster, DiscoveryPartitioningNamespace)
+	}
+	ids := make(map[string]bool)
+	for _, componentType := range Config.ComponentTypes {
+		if !componentTypeIdPattern.MatchString(componentType.Id) {
+			log.Fatal().Msgf("Component type id '%s' must consist of lower case letters, digits and dashes.", componentType.Id)
+		}
+		if slices.Contains(builtInComponentTypeIds, componentType.Id) || ids[componentType.Id] {
+			log.Fatal().Msgf("Component type id '%s' is already in use.", componentType.Id)
+		}
+		ids[componentType.Id] = true
+		if componentType.Label == "" || componentType.Query == "" {
+			log.Fatal().Msgf("Component type '%s' requires a label and a query.", componentType.Id)
+		}
+		for attribute, field := range componentType.Attributes {
+			if !slices.Contains(ComponentFields, field) {
+				log.Fatal().Msgf("Attribute '%s' of component type '%s' maps the unknown component field '%s'. Supported fields: %v", attribute, componentType.Id, field, ComponentFields)
+			}
+		}
+	}
+}
+
+// isBalancedStql reports whether all parentheses and string literals of the STQL expression are closed. Only then the
+// expression can be wrapped in parentheses and ANDed to another query without changing the meaning of that query.
+func isBalancedStql(expression string) bool {
+	depth := 0
+	inString := false
+	escaped := false
+	for _, r := range expression {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case inString:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0 && !inString
+}
+
+// NamePattern matches cluster or namespace names. Patterns enclosed in slashes, e.g. /^team-.+$/, are regular
+// expressions, all other patterns are globs supporting * and ?.
+type NamePattern struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+// NamePatterns is parsed from a comma-separated list.
+type NamePatterns []NamePattern
+
+func (n *NamePatterns) Decode(value string) error {
+	patterns := NamePatterns{}
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		parsed, err := ParseNamePattern(pattern)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, parsed)
+	}
+	*n = patterns
+	return nil
+}
+
+func ParseNamePattern(pattern string) (NamePattern, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		regex, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return NamePattern{}, fmt.Errorf("invalid regular expression %s: %w", pattern, err)
+		}
+		return NamePattern{pattern: pattern, regex: regex}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return NamePattern{}, fmt.Errorf("invalid glob %s: %w", pattern, err)
+	}
+	return NamePattern{pattern: pattern}, nil
+}
+
+func (p NamePattern) Matches(name string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+	matched, _ := path.Match(p.pattern, name)
+	return matched
+}
+
+// Exact returns the name matched by a pattern without wildcards.
+func (p NamePattern) Exact() (string, bool) {
+	if p.regex != nil || strings.ContainsAny(p.pattern, `*?[\`) {
+		return "", false
+	}
+	return p.pattern, true
+}
+
+func (n NamePatterns) Matches(name string) bool {
+	for _, pattern := range n {
+		if pattern.Matches(name) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBalancedStql(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       bool
+	}{
+		{name: "empty", expression: "", want: true},
+		{name: "plain", expression: `label = "team:shop"`, want: true},
+		{name: "nested parentheses", expression: `(type = "service" AND (label = "a" OR label = "b"))`, want: true},
+		{name: "unclosed parenthesis", expression: `(type = "service"`, want: false},
+		{name: "unopened parenthesis", expression: `type = "service")`, want: false},
+		{name: "closes the wrapping parenthesis", expression: `type = "a") OR (type = "b"`, want: false},
+		{name: "unterminated string", expression: `name = "checkout`, want: false},
+		{name: "parentheses in a string", expression: `name = "checkout (v2))"`, want: true},
+		{name: "escaped quote in a string", expression: `name = "say \"hi\" )"`, want: true},
+		{name: "escaped backslash ends the string", expression: `name = "path\\" AND (type = "a")`, want: true},
+		{name: "escaped quote leaves the string open", expression: `name = "checkout\"`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isBalancedStql(tt.expression))
+		})
+	}
+}
@@ -0,0 +1,401 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	extension_kit "github.com/steadybit/extension-kit"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-kit/extutil"
+	"github.com/steadybit/extension-stackstate/config"
+)
+
+const (
+	// relatedComponentsNeighbors checks the components directly related to the target, relatedComponentsConnected all
+	// components transitively related to it.
+	relatedComponentsNeighbors = "neighbors"
+	relatedComponentsConnected = "connected"
+)
+
+// relatedComponentKind labels the metrics of the related components, which can be of any component type.
+var relatedComponentKind = componentKind{
+	label:         discovery_kit_api.PluralLabel{One: "Component", Other: "Components"},
+	idAttribute:   attributeComponentId,
+	nameAttribute: attributeComponentName,
+}
+
+// BlastRadiusCheckAction verifies the health of all components related to the target, e.g. the services using the
+// database an attack breaks, without selecting each of them as a target of a status check.
+type BlastRadiusCheckAction struct {
+	kind componentKind
+}
+
+// Make sure action implements all required interfaces
+var (
+	_ action_kit_sdk.Action[BlastRadiusCheckState]           = (*BlastRadiusCheckAction)(nil)
+	_ action_kit_sdk.ActionWithStatus[BlastRadiusCheckState] = (*BlastRadiusCheckAction)(nil)
+)
+
+type BlastRadiusCheckState struct {
+	TargetType    string
+	ComponentId   string
+	ComponentName string
+	// Urn finds the target again if StackState re-creates it with a new id.
+	Urn               string
+	End               time.Time
+	RelatedComponents string
+	// AllowedComponents are the names, globs or regular expressions of the related components that may degrade.
+	AllowedComponents []string
+	// InitialHealthStates holds the health state of each related component by id before the attack, only a worse
+	// health state counts as degraded. Components appearing during the step are considered CLEAR before the attack.
+	InitialHealthStates map[string]string
+	// Affected holds the related components that degraded with their worst health state.
+	Affected []AffectedComponent
+}
+
+type AffectedComponent struct {
+	Id          string
+	Name        string
+	HealthState string
+}
+
+type GetRelatedComponentsApi interface {
+	GetRelatedComponents(ctx context.Context, query string, connected bool) (*resty.Response, ViewSnapshotResponseWrapper, error)
+}
+
+func NewBlastRadiusCheckActions() []action_kit_sdk.Action[BlastRadiusCheckState] {
+	kinds := append(append([]componentKind{serviceKind}, workloadKinds...), customKinds()...)
+	actions := make([]action_kit_sdk.Action[BlastRadiusCheckState], 0, len(kinds))
+	for _, kind := range kinds {
+		actions = append(actions, &BlastRadiusCheckAction{kind: kind})
+	}
+	return actions
+}
+
+func (m *BlastRadiusCheckAction) NewEmptyState() BlastRadiusCheckState {
+	return BlastRadiusCheckState{}
+}
+
+func (m *BlastRadiusCheckAction) Describe() action_kit_api.ActionDescription {
+	return action_kit_api.ActionDescription{
+		Id:          fmt.Sprintf("%s.blast-radius-check", m.kind.targetType),
+		Label:       fmt.Sprintf("StackState %s Blast Radius", m.kind.label.One),
+		Description: fmt.Sprintf("verifies that no component related to the %s degrades during the step, except for the allowed ones.", strings.ToLower(m.kind.label.One)),
+		Version:     extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:        new(serviceIcon),
+		TargetSelection: new(action_kit_api.TargetSelection{
+			TargetType:          m.kind.targetType,
+			QuantityRestriction: extutil.Ptr(action_kit_api.QuantityRestrictionAll),
+			SelectionTemplates:  new(m.kind.selectionTemplates()),
+		}),
+		Technology: new("StackState"),
+
+		Kind:        action_kit_api.Check,
+		TimeControl: action_kit_api.TimeControlInternal,
+		Parameters: []action_kit_api.ActionParameter{
+			{
+				Name:         "duration",
+				Label:        "Duration",
+				Description:  new(""),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				DefaultValue: new("30s"),
+				Order:        new(1),
+				Required:     new(true),
+			},
+			{
+				Name:         "relatedComponents",
+				Label:        "Related components",
+				Description:  new("Which components related to the target are checked."),
+				Type:         action_kit_api.ActionParameterTypeString,
+				DefaultValue: new(relatedComponentsNeighbors),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ExplicitParameterOption{
+						Label: "Direct neighbors",
+						Value: relatedComponentsNeighbors,
+					},
+					action_kit_api.ExplicitParameterOption{
+						Label: "All connected components",
+						Value: relatedComponentsConnected,
+					},
+				}),
+				Required: new(true),
+				Order:    new(2),
+			},
+			{
+				Name:        "allowedComponents",
+				Label:       "Allowed to degrade",
+				Description: new("Names of related components that may become DEVIATING or CRITICAL, e.g. the pods of an attacked deployment. Supports globs like `checkout-*` and regular expressions enclosed in slashes. The target itself is always allowed to degrade."),
+				Type:        action_kit_api.ActionParameterTypeStringArray,
+				Required:    new(false),
+				Order:       new(3),
+			},
+		},
+		Widgets: new([]action_kit_api.Widget{
+			action_kit_api.StateOverTimeWidget{
+				Type:  action_kit_api.ComSteadybitWidgetStateOverTime,
+				Title: fmt.Sprintf("StackState %s Blast Radius", m.kind.label.One),
+				Identity: action_kit_api.StateOverTimeWidgetIdentityConfig{
+					From: relatedComponentKind.idAttribute,
+				},
+				Label: action_kit_api.StateOverTimeWidgetLabelConfig{
+					From: relatedComponentKind.nameAttribute,
+				},
+				State: action_kit_api.StateOverTimeWidgetStateConfig{
+					From: attributeState,
+				},
+				Tooltip: action_kit_api.StateOverTimeWidgetTooltipConfig{
+					From: attributeTooltip,
+				},
+				Url: new(action_kit_api.StateOverTimeWidgetUrlConfig{
+					From: new(attributeUrl),
+				}),
+				Value: new(action_kit_api.StateOverTimeWidgetValueConfig{
+					Hide: new(true),
+				}),
+			},
+		}),
+		Status: new(action_kit_api.MutatingEndpointReferenceWithCallInterval{
+			CallInterval: new("1s"),
+		}),
+	}
+}
+
+func (m *BlastRadiusCheckAction) Prepare(_ context.Context, state *BlastRadiusCheckState, request action_kit_api.PrepareActionRequestBody) (*action_kit_api.PrepareResult, error) {
+	componentId := request.Target.Attributes[m.kind.idAttribute]
+	if len(componentId) == 0 {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Target is missing the '%s' attribute.", m.kind.idAttribute), nil))
+	}
+
+	duration := request.Config["duration"].(float64)
+
+	state.TargetType = m.kind.targetType
+	state.ComponentId = componentId[0]
+	if name := request.Target.Attributes[m.kind.nameAttribute]; len(name) > 0 {
+		state.ComponentName = name[0]
+	}
+	if urn := request.Target.Attributes[m.kind.urnAttribute]; len(urn) > 0 {
+		state.Urn = urn[0]
+	}
+	state.End = time.Now().Add(time.Millisecond * time.Duration(duration))
+	state.RelatedComponents = relatedComponentsNeighbors
+	if relatedComponents, ok := request.Config["relatedComponents"].(string); ok {
+		state.RelatedComponents = relatedComponents
+	}
+	if allowedComponents, ok := request.Config["allowedComponents"].([]any); ok {
+		for _, allowed := range allowedComponents {
+			pattern := strings.TrimSpace(fmt.Sprintf("%v", allowed))
+			if pattern == "" {
+				continue
+			}
+			if _, err := config.ParseNamePattern(pattern); err != nil {
+				return nil, new(extension_kit.ToError(fmt.Sprintf("Invalid allowed component '%s'.", pattern), err))
+			}
+			state.AllowedComponents = append(state.AllowedComponents, pattern)
+		}
+	}
+	state.InitialHealthStates = make(map[string]string)
+	return nil, nil
+}
+
+func (m *BlastRadiusCheckAction) Start(ctx context.Context, state *BlastRadiusCheckState) (*action_kit_api.StartResult, error) {
+	return StartBlastRadius(ctx, state, sharedSnapshotPoller())
+}
+
+func (m *BlastRadiusCheckAction) Status(ctx context.Context, state *BlastRadiusCheckState) (*action_kit_api.StatusResult, error) {
+	return CheckBlastRadius(ctx, state, sharedSnapshotPoller())
+}
+
+// StartBlastRadius takes the health states of the related components before the attack as the baseline.
+func StartBlastRadius(ctx context.Context, state *BlastRadiusCheckState, api GetRelatedComponentsApi) (*action_kit_api.StartResult, error) {
+	kind, ok := componentKindOf(state.TargetType)
+	if !ok {
+		return nil, unknownTargetTypeError(state.TargetType)
+	}
+	components, err := loadRelatedComponents(ctx, state, kind, api)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(components, state.isTarget) {
+		return &action_kit_api.StartResult{Error: targetNotFoundError(state, kind)}, nil
+	}
+	state.InitialHealthStates = make(map[string]string, len(components))
+	for _, component := range components {
+		if !state.isTarget(component) {
+			state.InitialHealthStates[strconv.Itoa(component.Id)] = component.State.HealthState
+		}
+	}
+	return nil, nil
+}
+
+func CheckBlastRadius(ctx context.Context, state *BlastRadiusCheckState, api GetRelatedComponentsApi) (*action_kit_api.StatusResult, error) {
+	now := time.Now()
+	kind, ok := componentKindOf(state.TargetType)
+	if !ok {
+		return nil, unknownTargetTypeError(state.TargetType)
+	}
+	components, err := loadRelatedComponents(ctx, state, kind, api)
+	if err != nil {
+		return nil, err
+	}
+	completed := now.After(state.End)
+	if !slices.ContainsFunc(components, state.isTarget) {
+		// Without the target StackState returns no related components either, the check must not pass on nothing.
+		return &action_kit_api.StatusResult{
+			Completed: true,
+			Error:     targetNotFoundError(state, kind),
+		}, nil
+	}
+
+	if state.InitialHealthStates == nil {
+		state.InitialHealthStates = make(map[string]string)
+	}
+	allowed := state.allowedPatterns()
+	metrics := make([]action_kit_api.Metric, 0, len(components))
+	for _, component := range components {
+		if state.isTarget(component) {
+			continue
+		}
+		metrics = append(metrics, *toMetric(&component, relatedComponentKind, now))
+
+		id := strconv.Itoa(component.Id)
+		healthState := component.State.HealthState
+		initialHealthState, known := state.InitialHealthStates[id]
+		if !known {
+			// The component didn't exist before the attack, e.g. a replacement pod, so it is expected to be CLEAR.
+			initialHealthState = "CLEAR"
+			state.InitialHealthStates[id] = initialHealthState
+		}
+		if isDeviating(healthState) && healthSeverity(healthState) > healthSeverity(initialHealthState) && !allowed.Matches(component.Name) {
+			state.recordAffected(id, component.Name, healthState)
+		}
+	}
+
+	var checkError *action_kit_api.ActionKitError
+	var summary *action_kit_api.Summary
+	if completed {
+		if len(state.InitialHealthStates) == 0 {
+			checkError = new(action_kit_api.ActionKitError{
+				Title: fmt.Sprintf("No components related to %s '%s' were found in StackState.",
+					strings.ToLower(kind.label.One),
+					state.ComponentName),
+				Status: extutil.Ptr(action_kit_api.Failed),
+			})
+		} else if len(state.Affected) > 0 {
+			checkError = new(action_kit_api.ActionKitError{
+				Title: fmt.Sprintf("Components related to %s '%s' degraded: %s.",
+					strings.ToLower(kind.label.One),
+					state.ComponentName,
+					affectedText(state.Affected)),
+				Status: extutil.Ptr(action_kit_api.Failed),
+			})
+		} else {
+			summary = new(action_kit_api.Summary{
+				Level: action_kit_api.SummaryLevelInfo,
+				Text: fmt.Sprintf("None of the %d components related to %s '%s' degraded.",
+					len(state.InitialHealthStates),
+					strings.ToLower(kind.label.One),
+					state.ComponentName),
+			})
+		}
+	}
+
+	return &action_kit_api.StatusResult{
+		Completed: completed,
+		Error:     checkError,
+		Metrics:   &metrics,
+		Summary:   summary,
+	}, nil
+}
+
+func loadRelatedComponents(ctx context.Context, state *BlastRadiusCheckState, kind componentKind, api GetRelatedComponentsApi) ([]Component, error) {
+	res, stackStateResponse, err := api.GetRelatedComponents(ctx, state.query(), state.RelatedComponents == relatedComponentsConnected)
+	if err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to retrieve the components related to %s %s from StackState.", strings.ToLower(kind.label.One), state.ComponentId), err))
+	}
+	if !res.IsSuccess() {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("StackState API responded with unexpected status code %d while retrieving the components related to %s %s.", res.StatusCode(), strings.ToLower(kind.label.One), state.ComponentId), nil))
+	}
+	return stackStateResponse.ViewSnapshotResponse.Components, nil
+}
+
+func targetNotFoundError(state *BlastRadiusCheckState, kind componentKind) *action_kit_api.ActionKitError {
+	return new(action_kit_api.ActionKitError{
+		Title: fmt.Sprintf("%s '%s' (id %s) was not found in StackState, its related components can't be checked.",
+			kind.label.One,
+			state.ComponentName,
+			state.ComponentId),
+		Status: extutil.Ptr(action_kit_api.Failed),
+	})
+}
+
+// query finds the target by its URN, which survives StackState re-creating the component, or else by its id.
+func (s *BlastRadiusCheckState) query() string {
+	if s.Urn != "" {
+		return fmt.Sprintf("(identifier = %s)", stqlString(s.Urn))
+	}
+	return fmt.Sprintf("(id = %s)", stqlString(s.ComponentId))
+}
+
+func (s *BlastRadiusCheckState) isTarget(component Component) bool {
+	return strconv.Itoa(component.Id) == s.ComponentId || (s.Urn != "" && componentUrn(component) == s.Urn)
+}
+
+// allowedPatterns parses the allowed components, which were already validated by Prepare.
+func (s *BlastRadiusCheckState) allowedPatterns() config.NamePatterns {
+	patterns := make(config.NamePatterns, 0, len(s.AllowedComponents))
+	for _, allowed := range s.AllowedComponents {
+		if pattern, err := config.ParseNamePattern(allowed); err == nil {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// recordAffected adds a degraded component or updates its worst health state.
+func (s *BlastRadiusCheckState) recordAffected(id string, name string, healthState string) {
+	for i, affected := range s.Affected {
+		if affected.Id == id {
+			if healthSeverity(healthState) > healthSeverity(affected.HealthState) {
+				s.Affected[i].HealthState = healthState
+			}
+			return
+		}
+	}
+	s.Affected = append(s.Affected, AffectedComponent{Id: id, Name: name, HealthState: healthState})
+}
+
+// affectedText renders the affected components for messages, e.g. 'orders' (CRITICAL), 'payments' (DEVIATING).
+func affectedText(affected []AffectedComponent) string {
+	sorted := slices.Clone(affected)
+	slices.SortFunc(sorted, func(a, b AffectedComponent) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	texts := make([]string, 0, len(sorted))
+	for _, component := range sorted {
+		texts = append(texts, fmt.Sprintf("'%s' (%s)", component.Name, component.HealthState))
+	}
+	return strings.Join(texts, ", ")
+}
+
+// healthSeverity orders the health states, UNKNOWN counts as healthy like in isDeviating.
+func healthSeverity(healthState string) int {
+	switch healthState {
+	case "CRITICAL":
+		return 2
+	case "DEVIATING":
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/extension-kit/extutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type getRelatedComponentsApiMock struct {
+	mock.Mock
+}
+
+func (m *getRelatedComponentsApiMock) GetRelatedComponents(ctx context.Context, query string, connected bool) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	args := m.Called(ctx, query, connected)
+	return args.Get(0).(*resty.Response), args.Get(1).(ViewSnapshotResponseWrapper), args.Error(2)
+}
+
+func relatedComponents(healthStates map[int]string) ViewSnapshotResponseWrapper {
+	names := map[int]string{1: "postgres", 2: "checkout", 3: "orders", 4: "postgres-0"}
+	var wrapper ViewSnapshotResponseWrapper
+	for id := 1; id <= 4; id++ {
+		wrapper.ViewSnapshotResponse.Components = append(wrapper.ViewSnapshotResponse.Components, Component{
+			Id:          id,
+			Name:        names[id],
+			State:       State{HealthState: healthStates[id]},
+			Identifiers: []string{"urn:kubernetes:/prod:shop:service/" + names[id]},
+		})
+	}
+	return wrapper
+}
+
+func blastRadiusCheckState() BlastRadiusCheckState {
+	return BlastRadiusCheckState{
+		TargetType:          serviceTargetType,
+		ComponentId:         "1",
+		ComponentName:       "postgres",
+		Urn:                 "urn:kubernetes:/prod:shop:service/postgres",
+		End:                 time.Now().Add(time.Hour),
+		RelatedComponents:   relatedComponentsNeighbors,
+		AllowedComponents:   []string{"postgres-*"},
+		InitialHealthStates: map[string]string{},
+	}
+}
+
+func TestBlastRadiusCheckPrepare(t *testing.T) {
+	action := &BlastRadiusCheckAction{kind: serviceKind}
+	request := extutil.JsonMangle(action_kit_api.PrepareActionRequestBody{
+		Config: map[string]any{
+			"duration":          1000 * 60,
+			"relatedComponents": relatedComponentsConnected,
+			"allowedComponents": []string{"postgres-*", "/^pgbouncer-[0-9]+$/"},
+		},
+		Target: &action_kit_api.Target{
+			Attributes: map[string][]string{
+				"stackstate.service.id":  {"1"},
+				"stackstate.service.urn": {"urn:kubernetes:/prod:shop:service/postgres"},
+				"k8s.service.name":       {"postgres"},
+			},
+		},
+	})
+	state := action.NewEmptyState()
+
+	result, err := action.Prepare(context.TODO(), &state, request)
+
+	require.Nil(t, result)
+	require.NoError(t, err)
+	assert.Equal(t, "1", state.ComponentId)
+	assert.Equal(t, "postgres", state.ComponentName)
+	assert.Equal(t, "urn:kubernetes:/prod:shop:service/postgres", state.Urn)
+	assert.Equal(t, relatedComponentsConnected, state.RelatedComponents)
+	assert.Equal(t, []string{"postgres-*", "/^pgbouncer-[0-9]+$/"}, state.AllowedComponents)
+
+	request.Config["allowedComponents"] = []any{"/[/"}
+	_, err = action.Prepare(context.TODO(), &state, request)
+	require.Error(t, err)
+}
+
+func TestBlastRadiusCheckReportsDegradedDependents(t *testing.T) {
+	state := blastRadiusCheckState()
+	state.RelatedComponents = relatedComponentsConnected
+	mockedApi := new(getRelatedComponentsApiMock)
+	mockedApi.On("GetRelatedComponents", mock.Anything, `(identifier = "urn:kubernetes:/prod:shop:service/postgres")`, true).
+		Return(apiResponseWithStatus(200), relatedComponents(map[int]string{1: "CLEAR", 2: "CLEAR", 3: "DEVIATING", 4: "CLEAR"}), nil).Once()
+	mockedApi.On("GetRelatedComponents", mock.Anything, mock.Anything, true).
+		Return(apiResponseWithStatus(200), relatedComponents(map[int]string{1: "CRITICAL", 2: "DEVIATING", 3: "DEVIATING", 4: "CRITICAL"}), nil).Once()
+	mockedApi.On("GetRelatedComponents", mock.Anything, mock.Anything, true).
+		Return(apiResponseWithStatus(200), relatedComponents(map[int]string{1: "CRITICAL", 2: "CRITICAL", 3: "CRITICAL", 4: "CLEAR"}), nil)
+
+	start, err := StartBlastRadius(context.TODO(), &state, mockedApi)
+	require.NoError(t, err)
+	assert.Nil(t, start)
+	assert.Equal(t, map[string]string{"2": "CLEAR", "3": "DEVIATING", "4": "CLEAR"}, state.InitialHealthStates)
+
+	status, err := CheckBlastRadius(context.TODO(), &state, mockedApi)
+	require.NoError(t, err)
+	assert.Nil(t, status.Error, "the check fails at the end of the step")
+	assert.Len(t, *status.Metrics, 3, "the target itself has no metric")
+
+	state.End = time.Now().Add(-time.Second)
+	status, err = CheckBlastRadius(context.TODO(), &state, mockedApi)
+	require.NoError(t, err)
+	assert.True(t, status.Completed)
+	require.NotNil(t, status.Error)
+	assert.Equal(t, "Components related to service 'postgres' degraded: 'checkout' (CRITICAL), 'orders' (CRITICAL).", status.Error.Title)
+	assert.Equal(t, action_kit_api.Failed, *status.Error.Status)
+}
+
+func TestBlastRadiusCheckSucceedsWithoutDegradedDependents(t *testing.T) {
+	state := blastRadiusCheckState()
+	state.Urn = ""
+	state.End = time.Now().Add(-time.Second)
+	mockedApi := new(getRelatedComponentsApiMock)
+	mockedApi.On("GetRelatedComponents", mock.Anything, `(id = "1")`, false).
+		Return(apiResponseWithStatus(200), relatedComponents(map[int]string{1: "CRITICAL", 2: "CLEAR", 3: "CLEAR", 4: "CRITICAL"}), nil)
+
+	status, err := CheckBlastRadius(context.TODO(), &state, mockedApi)
+
+	require.NoError(t, err)
+	assert.True(t, status.Completed)
+	assert.Nil(t, status.Error)
+	require.NotNil(t, status.Summary)
+	assert.Equal(t, "None of the 3 components related to service 'postgres' degraded.", status.Summary.Text)
+}
+
+func TestBlastRadiusCheckErrorsOnUnexpectedResponse(t *testing.T) {
+	state := blastRadiusCheckState()
+	mockedApi := new(getRelatedComponentsApiMock)
+	mockedApi.On("GetRelatedComponents", mock.Anything, mock.Anything, false).
+		Return(apiResponseWithStatus(500), ViewSnapshotResponseWrapper{}, nil)
+
+	_, err := CheckBlastRadius(context.TODO(), &state, mockedApi)
+
+	require.Error(t, err)
+}
+
+func TestBlastRadiusCheckFailsWithoutTarget(t *testing.T) {
+	state := blastRadiusCheckState()
+	mockedApi := new(getRelatedComponentsApiMock)
+	mockedApi.On("GetRelatedComponents", mock.Anything, mock.Anything, false).
+		Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+
+	status, err := CheckBlastRadius(context.TODO(), &state, mockedApi)
+
+	require.NoError(t, err)
+	assert.True(t, status.Completed)
+	require.NotNil(t, status.Error)
+	assert.Equal(t, "Service 'postgres' (id 1) was not found in StackState, its related components can't be checked.", status.Error.Title)
+	assert.Equal(t, action_kit_api.Failed, *status.Error.Status)
+}
+
+func TestBlastRadiusCheckFailsWithoutRelatedComponents(t *testing.T) {
+	state := blastRadiusCheckState()
+	state.End = time.Now().Add(-time.Second)
+	var wrapper ViewSnapshotResponseWrapper
+	wrapper.ViewSnapshotResponse.Components = []Component{{Id: 1, Name: "postgres", State: State{HealthState: "CRITICAL"}}}
+	mockedApi := new(getRelatedComponentsApiMock)
+	mockedApi.On("GetRelatedComponents", mock.Anything, mock.Anything, false).
+		Return(apiResponseWithStatus(200), wrapper, nil)
+
+	status, err := CheckBlastRadius(context.TODO(), &state, mockedApi)
+
+	require.NoError(t, err)
+	assert.True(t, status.Completed)
+	assert.Nil(t, status.Summary)
+	require.NotNil(t, status.Error)
+	assert.Equal(t, "No components related to service 'postgres' were found in StackState.", status.Error.Title)
+}
+
+func TestBlastRadiusCheckBlamesComponentsAppearingDegraded(t *testing.T) {
+	state := blastRadiusCheckState()
+	state.InitialHealthStates = map[string]string{"2": "CLEAR"}
+	state.End = time.Now().Add(-time.Second)
+	mockedApi := new(getRelatedComponentsApiMock)
+	mockedApi.On("GetRelatedComponents", mock.Anything, mock.Anything, false).
+		Return(apiResponseWithStatus(200), relatedComponents(map[int]string{1: "CRITICAL", 2: "CLEAR", 3: "CRITICAL", 4: "CRITICAL"}), nil)
+
+	status, err := CheckBlastRadius(context.TODO(), &state, mockedApi)
+
+	require.NoError(t, err)
+	require.NotNil(t, status.Error)
+	assert.Equal(t, "Components related to service 'postgres' degraded: 'orders' (CRITICAL).", status.Error.Title)
+}
+
+func TestBlastRadiusCheckStartFailsWithoutTarget(t *testing.T) {
+	state := blastRadiusCheckState()
+	mockedApi := new(getRelatedComponentsApiMock)
+	mockedApi.On("GetRelatedComponents", mock.Anything, mock.Anything, false).
+		Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+
+	start, err := StartBlastRadius(context.TODO(), &state, mockedApi)
+
+	require.NoError(t, err)
+	require.NotNil(t, start.Error)
+	assert.Equal(t, action_kit_api.Failed, *start.Error.Status)
+}
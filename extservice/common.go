@@ -8,16 +8,58 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	extension_kit "github.com/steadybit/extension-kit"
+	"github.com/steadybit/extension-stackstate/config"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	serviceTargetType          = "com.steadybit.extension_stackstate.service"
+	deploymentTargetType       = "com.steadybit.extension_stackstate.deployment"
+	statefulSetTargetType      = "com.steadybit.extension_stackstate.statefulset"
+	daemonSetTargetType        = "com.steadybit.extension_stackstate.daemonset"
+	podTargetType              = "com.steadybit.extension_stackstate.pod"
+	monitorTargetType          = "com.steadybit.extension_stackstate.monitor"
 	serviceIcon                = "data:image/svg+xml;base64,PHN2ZyB4bWxucz0iaHR0cDovL3d3dy53My5vcmcvMjAwMC9zdmciIHZpZXdCb3g9IjAgMCA5Ny4yNyA5Ni42MSI+PGcgZmlsbD0iY3VycmVudENvbG9yIj48cGF0aCBkPSJNMTcuOTUgMjkuN2wzMC43OS0xNy43Mkw3OS41MyAyOS43IDUwLjY0IDQ2LjI5Yy0xLjE3LjY5LTIuNjUuNjktMy44MSAwTDE3Ljk1IDI5Ljd6Ii8+PHBhdGggZD0iTTQ2Ljg0IDUzLjY0TDI2LjcxIDQyLjA2bC04Ljc2IDUuMDYgMjguODggMTYuNTljMS4xNy42OSAyLjY1LjY5IDMuODEgMGwyOC44OC0xNi41OS04Ljc2LTUuMDYtMjAuMTMgMTEuNThjLTEuMTcuNjktMi42NS42OS0zLjgxIDB6Ii8+PHBhdGggZD0iTTQ2Ljg0IDcxLjQ1TDI2LjcxIDU5Ljg3bC04Ljc2IDUuMDYgMjguODggMTYuNTljMS4xNy42OSAyLjY1LjY5IDMuODEgMGwyOC44OC0xNi41OS04Ljc2LTUuMDYtMjAuMDggMTEuNThjLTEuMjEuNjktMi42OS42OS0zLjg1IDB6Ii8+PGc+PHBhdGggZD0iTTAgNDguMzJjMCA4LjU2IDIuMjUgMTYuNTkgNi4xNiAyMy41Nmw1LjQ2LTMuMTZ2LTQuOTdjMC0xLjM0Ljc0LTIuNiAxLjkxLTMuMjhsNi45LTMuOTgtNi42OC0zLjg1Yy0xLjE3LS42OS0xLjkxLTEuOTUtMS45MS0zLjI4VjQ1LjljMC0xLjM0LjctMi42NCAxLjkxLTMuMjhsNi42NC0zLjg5LTYuNTktMy44Yy0xLjE3LS42OS0xLjkxLTEuOTUtMS45MS0zLjI4di0zLjAyYzAtMS4zOS43NC0yLjY0IDEuOTEtMy4zM0w0NS4yMyA3LjI3Vi4xM0MxOS45OSAxLjgxIDAgMjIuNzggMCA0OC4zMnpNOTcuMjcgNDguMjRjMCA4LjU2LTIuMjUgMTYuNTktNi4xNiAyMy41NmwtNS40Ni0zLjE2di00Ljk3YzAtMS4zNC0uNzQtMi42LTEuOTEtMy4yOGwtNi45LTMuOTggNi42OC0zLjg1YzEuMTctLjY5IDEuOTEtMS45NSAxLjkxLTMuMjh2LTMuNDZjMC0xLjM0LS43LTIuNjQtMS45MS0zLjI4bC02LjY0LTMuODkgNi41OS0zLjhjMS4xNy0uNjkgMS45MS0xLjk1IDEuOTEtMy4yOHYtMy4wMmMwLTEuMzktLjc0LTIuNjQtMS45MS0zLjMzTDUyLjA0IDcuMTdWMGMyNS4yNCAxLjY5IDQ1LjIzIDIyLjY5IDQ1LjIzIDQ4LjI0ek00OC42MSA5Ni42MWMxNS45NiAwIDMwLjE0LTcuNjkgMzguOTktMTkuNTNsLTguMzMtNC43NUw1MC42OSA4OC43Yy0xLjE3LjY5LTIuNjUuNjktMy44MSAwTDE4IDcyLjE1bC01LjMgMy4wMi0uNi4zNC0yLjU2IDEuNDdjOC44OSAxMS44OSAyMy4wNyAxOS42MyAzOS4wNyAxOS42M3oiLz48L2c+PC9nPjwvc3ZnPg=="
 	statusCheckModeAtLeastOnce = "atLeastOnce"
 	statusCheckModeAllTheTime  = "allTheTime"
+	// statusCheckModeDetectedWithin expects the component to leave CLEAR within the detection deadline.
+	statusCheckModeDetectedWithin = "detectedWithin"
+	// statusCheckModeRecoversWithin tolerates deviations as long as the expected status is back within the recovery window.
+	statusCheckModeRecoversWithin = "recoversWithin"
+	// statusCheckModePercentageOfTime expects the status in at least a minimum percentage of the polls.
+	statusCheckModePercentageOfTime = "percentageOfTime"
+	// missingComponentError, missingComponentFail, missingComponentUnknown and missingComponentIgnore decide what it
+	// means when StackState doesn't return the checked component.
+	missingComponentError   = "error"
+	missingComponentFail    = "fail"
+	missingComponentUnknown = "unknown"
+	missingComponentIgnore  = "ignore"
+	// errorKindAtLeastOnce, errorKindTransitions and errorKindMissingComponent tell which check reported the error
+	// of a status check, other errors have no kind.
+	errorKindAtLeastOnce      = "atLeastOnce"
+	errorKindTransitions      = "transitions"
+	errorKindMissingComponent = "missingComponent"
 
 	attributeServiceId     = "stackstate.service.id"
+	attributeServiceUrn    = "stackstate.service.urn"
+	attributeComponentId   = "stackstate.component.id"
+	attributeComponentUrn  = "stackstate.component.urn"
+	attributeComponentName = "stackstate.component.name"
 	attributeK8ServiceName = "k8s.service.name"
+	attributeK8Deployment  = "k8s.deployment"
+	attributeK8StatefulSet = "k8s.statefulset"
+	attributeK8DaemonSet   = "k8s.daemonset"
+	attributeK8PodName     = "k8s.pod.name"
+	attributeMonitorId     = "stackstate.monitor.id"
+	attributeMonitorName   = "stackstate.monitor.name"
+	attributeMonitorStatus = "stackstate.monitor.status"
+	attributeMonitorTag    = "stackstate.monitor.tag"
 	attributeK8ClusterName = "k8s.cluster-name"
 	attributeK8Namespace   = "k8s.namespace"
 	attributeState         = "state"
@@ -25,6 +67,134 @@ const (
 	attributeUrl           = "url"
 )
 
+// componentKind describes a StackState component type that is discovered as a Steadybit target type and can be
+// checked by a status check action.
+type componentKind struct {
+	targetType string
+	// componentType is the StackState component type used in the STQL query, e.g. "deployment".
+	componentType string
+	// label is the human-readable name of the component type, e.g. "Deployment".
+	label       discovery_kit_api.PluralLabel
+	idAttribute string
+	// urnAttribute holds the stable URN identifier, which survives StackState re-creating the component.
+	urnAttribute  string
+	nameAttribute string
+	// attributeExcludes returns the configured discovery attribute excludes of the component type.
+	attributeExcludes func() []string
+	// kubernetes marks component types carrying the Kubernetes cluster and namespace properties.
+	kubernetes bool
+	// enrichedTargetTypes are the target types of other extensions that get the StackState ids of the component.
+	enrichedTargetTypes []string
+	// query replaces the component type query for user-defined component types.
+	query string
+	// attributes maps target attributes to component fields for user-defined component types.
+	attributes map[string]string
+	// ownerPrefix returns the name prefix the component shares with the other components of its owner, e.g. the pods
+	// of a ReplicaSet. The status check uses it to follow a component that is replaced under a new name.
+	ownerPrefix func(name string) string
+}
+
+var (
+	serviceKind = componentKind{
+		targetType:          serviceTargetType,
+		componentType:       "service",
+		label:               discovery_kit_api.PluralLabel{One: "Service", Other: "Services"},
+		idAttribute:         attributeServiceId,
+		urnAttribute:        attributeServiceUrn,
+		nameAttribute:       attributeK8ServiceName,
+		attributeExcludes:   func() []string { return config.Config.DiscoveryAttributesExcludesService },
+		kubernetes:          true,
+		enrichedTargetTypes: []string{kubernetesDeploymentTargetType, containerTargetType},
+	}
+	deploymentKind = componentKind{
+		targetType:          deploymentTargetType,
+		componentType:       "deployment",
+		label:               discovery_kit_api.PluralLabel{One: "Deployment", Other: "Deployments"},
+		idAttribute:         attributeComponentId,
+		urnAttribute:        attributeComponentUrn,
+		nameAttribute:       attributeK8Deployment,
+		attributeExcludes:   func() []string { return config.Config.DiscoveryAttributesExcludesDeployment },
+		kubernetes:          true,
+		enrichedTargetTypes: []string{kubernetesDeploymentTargetType, containerTargetType},
+	}
+	statefulSetKind = componentKind{
+		targetType:          statefulSetTargetType,
+		componentType:       "statefulset",
+		label:               discovery_kit_api.PluralLabel{One: "StatefulSet", Other: "StatefulSets"},
+		idAttribute:         attributeComponentId,
+		urnAttribute:        attributeComponentUrn,
+		nameAttribute:       attributeK8StatefulSet,
+		attributeExcludes:   func() []string { return config.Config.DiscoveryAttributesExcludesStatefulSet },
+		kubernetes:          true,
+		enrichedTargetTypes: []string{kubernetesStatefulSetTargetType, containerTargetType},
+	}
+	daemonSetKind = componentKind{
+		targetType:          daemonSetTargetType,
+		componentType:       "daemonset",
+		label:               discovery_kit_api.PluralLabel{One: "DaemonSet", Other: "DaemonSets"},
+		idAttribute:         attributeComponentId,
+		urnAttribute:        attributeComponentUrn,
+		nameAttribute:       attributeK8DaemonSet,
+		attributeExcludes:   func() []string { return config.Config.DiscoveryAttributesExcludesDaemonSet },
+		kubernetes:          true,
+		enrichedTargetTypes: []string{kubernetesDaemonSetTargetType, containerTargetType},
+	}
+	podKind = componentKind{
+		targetType:          podTargetType,
+		componentType:       "pod",
+		label:               discovery_kit_api.PluralLabel{One: "Pod", Other: "Pods"},
+		idAttribute:         attributeComponentId,
+		urnAttribute:        attributeComponentUrn,
+		nameAttribute:       attributeK8PodName,
+		attributeExcludes:   func() []string { return config.Config.DiscoveryAttributesExcludesPod },
+		kubernetes:          true,
+		enrichedTargetTypes: []string{kubernetesPodTargetType},
+		ownerPrefix:         podOwnerPrefix,
+	}
+	// workloadKinds lists the Kubernetes workload component types discovered next to the services.
+	workloadKinds = []componentKind{deploymentKind, statefulSetKind, daemonSetKind, podKind}
+)
+
+// componentKindOf returns the component kind of the given target type. States prepared without a target type
+// belong to the service status check. The target type of a component type that was removed from the configuration
+// is not found.
+func componentKindOf(targetType string) (componentKind, bool) {
+	if targetType == "" {
+		return serviceKind, true
+	}
+	for _, kind := range append(append([]componentKind{serviceKind}, workloadKinds...), customKinds()...) {
+		if kind.targetType == targetType {
+			return kind, true
+		}
+	}
+	return componentKind{}, false
+}
+
+func unknownTargetTypeError(targetType string) error {
+	return new(extension_kit.ToError(fmt.Sprintf("Target type '%s' is unknown, its component type may have been removed from the configuration.", targetType), nil))
+}
+
+// selectionTemplates finds the targets of the component kind by cluster, namespace and name, or by name for component
+// types without the Kubernetes properties.
+func (k componentKind) selectionTemplates() []action_kit_api.TargetSelectionTemplate {
+	if k.kubernetes {
+		return []action_kit_api.TargetSelectionTemplate{
+			{
+				Label:       fmt.Sprintf("%s name", strings.ToLower(k.label.One)),
+				Description: new(fmt.Sprintf("Find %[1]s by cluster, namespace and %[1]s", strings.ToLower(k.label.One))),
+				Query:       fmt.Sprintf("k8s.cluster-name=\"\" AND k8s.namespace=\"\" AND %s=\"\"", k.nameAttribute),
+			},
+		}
+	}
+	return []action_kit_api.TargetSelectionTemplate{
+		{
+			Label:       fmt.Sprintf("%s name", strings.ToLower(k.label.One)),
+			Description: new(fmt.Sprintf("Find %s by name", strings.ToLower(k.label.One))),
+			Query:       fmt.Sprintf("%s=\"\"", k.nameAttribute),
+		},
+	}
+}
+
 var Client *StackStateHttpClient
 
 type StackStateHttpClient struct {
@@ -36,7 +206,63 @@ func (s *StackStateHttpClient) GetServiceSnapshot(ctx context.Context, serviceId
 }
 
 func (s *StackStateHttpClient) GetServiceSnapshots(ctx context.Context) (*resty.Response, ViewSnapshotResponseWrapper, error) {
-	return s.executeSnapshotQuery(ctx, `(type = "service")`)
+	return s.getComponentSnapshots(ctx, serviceKind.componentType, snapshotRelations{indirect: config.Config.DiscoveryServiceRelations})
+}
+
+func (s *StackStateHttpClient) GetComponentSnapshots(ctx context.Context, componentType string) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	return s.getComponentSnapshots(ctx, componentType, snapshotRelations{})
+}
+
+func (s *StackStateHttpClient) getComponentSnapshots(ctx context.Context, componentType string, relations snapshotRelations) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	query := fmt.Sprintf("(type = %s)", stqlString(componentType))
+	if config.Config.DiscoveryFilter != "" {
+		query = fmt.Sprintf("%s AND (%s)", query, config.Config.DiscoveryFilter)
+	}
+	if scope := discoveryScopeStql(); scope != "" {
+		query = fmt.Sprintf("%s AND (%s)", query, scope)
+	}
+	return s.executePartitionedQuery(ctx, query, relations)
+}
+
+func (s *StackStateHttpClient) GetMonitors(ctx context.Context) (*resty.Response, MonitorsResponse, error) {
+	var stackStateResponse MonitorsResponse
+	response, err := s.Client.R().
+		SetContext(ctx).
+		SetResult(&stackStateResponse).
+		Get("/monitors")
+	return response, stackStateResponse, err
+}
+
+func (s *StackStateHttpClient) GetMonitorStatus(ctx context.Context, monitorId string) (*resty.Response, MonitorStatusResponse, error) {
+	var stackStateResponse MonitorStatusResponse
+	response, err := s.Client.R().
+		SetContext(ctx).
+		SetPathParam("monitorId", monitorId).
+		SetResult(&stackStateResponse).
+		Get("/monitors/{monitorId}/status")
+	return response, stackStateResponse, err
+}
+
+func (s *StackStateHttpClient) GetHealthHistory(ctx context.Context, componentId string, start time.Time, end time.Time) (*resty.Response, HealthHistoryResponse, error) {
+	var stackStateResponse HealthHistoryResponse
+	response, err := s.Client.R().
+		SetContext(ctx).
+		SetPathParam("componentId", componentId).
+		SetQueryParam("startTime", strconv.FormatInt(start.UnixMilli(), 10)).
+		SetQueryParam("endTime", strconv.FormatInt(end.UnixMilli(), 10)).
+		SetResult(&stackStateResponse).
+		Get("/components/{componentId}/healthHistory")
+	return response, stackStateResponse, err
+}
+
+func (s *StackStateHttpClient) QuerySnapshots(ctx context.Context, query string) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	return s.executeSnapshotQuery(ctx, query)
+}
+
+// GetRelatedComponents returns the components matching the query together with their direct neighbors, or with all
+// components connected to them.
+func (s *StackStateHttpClient) GetRelatedComponents(ctx context.Context, query string, connected bool) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	return s.executeSnapshotQueryWithRelations(ctx, query, snapshotRelations{neighboring: !connected, connected: connected})
 }
 
 // stqlString renders a value as a quoted, escaped string literal using JSON string escaping,
@@ -47,7 +273,22 @@ func stqlString(value string) string {
 	return string(encoded)
 }
 
+// snapshotRelations selects the relations a snapshot query returns next to the components matching the query.
+type snapshotRelations struct {
+	// indirect also returns the relations between the matching components that run through other components, e.g.
+	// from a service via its pods to another service.
+	indirect bool
+	// neighboring adds the components directly related to the matching components, connected adds all components
+	// transitively related to them.
+	neighboring bool
+	connected   bool
+}
+
 func (s *StackStateHttpClient) executeSnapshotQuery(ctx context.Context, query string) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	return s.executeSnapshotQueryWithRelations(ctx, query, snapshotRelations{})
+}
+
+func (s *StackStateHttpClient) executeSnapshotQueryWithRelations(ctx context.Context, query string, relations snapshotRelations) (*resty.Response, ViewSnapshotResponseWrapper, error) {
 	// Encode the query as a JSON string so it is correctly escaped inside the request body.
 	queryJSON, err := json.Marshal(query)
 	if err != nil {
@@ -60,23 +301,38 @@ func (s *StackStateHttpClient) executeSnapshotQuery(ctx context.Context, query s
     "metadata": {
         "_type": "QueryMetadata",
         "groupingEnabled": false,
-        "showIndirectRelations": false,
+        "showIndirectRelations": %t,
         "minGroupSize": 0,
         "groupedByLayer": false,
         "groupedByDomain": false,
         "groupedByRelation": false,
         "showCause": "NONE",
         "autoGrouping": false,
-        "connectedComponents": false,
-        "neighboringComponents": false,
+        "connectedComponents": %t,
+        "neighboringComponents": %t,
         "showFullComponent": false
     }
-  }`, queryJSON)
-	var stackStateResponse ViewSnapshotResponseWrapper
+  }`, queryJSON, relations.indirect, relations.connected, relations.neighboring)
 	response, err := s.Client.R().
 		SetContext(ctx).
 		SetBody([]byte(requestBody)).
-		SetResult(&stackStateResponse).
+		SetDoNotParseResponse(true).
 		Post("/snapshot")
-	return response, stackStateResponse, err
+	if err != nil {
+		return response, ViewSnapshotResponseWrapper{}, err
+	}
+	defer func() {
+		_ = response.RawBody().Close()
+	}()
+	if !response.IsSuccess() {
+		// Keep the beginning of the body for the error logs of the callers, the body of retried responses is gone.
+		body, _ := io.ReadAll(io.LimitReader(response.RawBody(), maxErrorBodySize))
+		response.SetBody(body)
+		return response, ViewSnapshotResponseWrapper{}, nil
+	}
+	stackStateResponse, err := decodeSnapshotResponse(response.RawBody())
+	if err != nil {
+		return response, ViewSnapshotResponseWrapper{}, fmt.Errorf("failed to decode snapshot response: %w", err)
+	}
+	return response, stackStateResponse, nil
 }
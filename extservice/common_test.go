@@ -10,8 +10,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_sdk"
+	"github.com/steadybit/extension-stackstate/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -44,3 +47,20 @@ func TestGetServiceSnapshot_EscapesServiceId(t *testing.T) {
 	// ...and the id stays inside a single, escaped STQL string literal (no breakout).
 	assert.Equal(t, `(id = "1\") OR (1=1")`, body.Query)
 }
+
+func TestDiscoveryCallIntervalFollowsDiscoveryInterval(t *testing.T) {
+	discoveryInterval := config.Config.DiscoveryInterval
+	t.Cleanup(func() { config.Config.DiscoveryInterval = discoveryInterval })
+	config.Config.DiscoveryInterval = 5 * time.Minute
+
+	discoveries := []discovery_kit_sdk.Discovery{
+		&serviceDiscovery{},
+		&workloadDiscovery{kind: deploymentKind},
+		&customDiscovery{kind: componentKind{targetType: "com.steadybit.extension_stackstate.aws"}},
+		&monitorDiscovery{},
+	}
+	for _, discovery := range discoveries {
+		description := discovery.Describe()
+		assert.Equal(t, "5m0s", *description.Discover.CallInterval, description.Id)
+	}
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"strings"
+)
+
+const (
+	attributeLabelPrefix   = "stackstate.label."
+	attributeTag           = "stackstate.tag"
+	attributeLayer         = "stackstate.layer"
+	attributeDomain        = "stackstate.domain"
+	attributeComponentType = "stackstate.component.type"
+	// attributeHealthState is refreshed with every discovery, so it can be used to exclude components that are already
+	// unhealthy from an experiment.
+	attributeHealthState = "stackstate.health-state"
+	// attributePropagatedHealthState also reflects the health of the components the component depends on.
+	attributePropagatedHealthState = "stackstate.propagated-health-state"
+	attributePropertyPrefix        = "stackstate.property."
+)
+
+// addComponentAttributes adds the StackState health states, labels, layer, domain, component type and properties of
+// the component to the target attributes. Labels in the key:value format, e.g. "team:checkout", become
+// stackstate.label.<key> attributes, all other labels become stackstate.tag values.
+func addComponentAttributes(attributes map[string][]string, component Component) {
+	for _, label := range component.Labels {
+		if key, value, found := strings.Cut(label.Name, ":"); found && key != "" {
+			attributes[attributeLabelPrefix+key] = append(attributes[attributeLabelPrefix+key], value)
+		} else {
+			attributes[attributeTag] = append(attributes[attributeTag], label.Name)
+		}
+	}
+	if component.State.HealthState != "" {
+		attributes[attributeHealthState] = []string{component.State.HealthState}
+	}
+	if component.State.PropagatedHealthState != "" {
+		attributes[attributePropagatedHealthState] = []string{component.State.PropagatedHealthState}
+	}
+	if component.LayerName != "" {
+		attributes[attributeLayer] = []string{component.LayerName}
+	}
+	if component.DomainName != "" {
+		attributes[attributeDomain] = []string{component.DomainName}
+	}
+	if component.TypeName != "" {
+		attributes[attributeComponentType] = []string{component.TypeName}
+	}
+	for key, value := range component.Properties.Values {
+		if value != "" {
+			attributes[attributePropertyPrefix+key] = []string{value}
+		}
+	}
+}
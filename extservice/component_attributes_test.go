@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToServiceAddsComponentAttributes(t *testing.T) {
+	target := toService(Component{
+		Id:         123,
+		Name:       "checkout",
+		TypeName:   "service",
+		LayerName:  "Services",
+		DomainName: "Kubernetes",
+		Labels:     []Label{{Name: "team:shop"}, {Name: "env:prod"}, {Name: "team:payments"}, {Name: "critical"}},
+		Properties: Properties{Values: map[string]string{"tier": "frontend", "empty": ""}},
+		State:      State{HealthState: "CRITICAL", PropagatedHealthState: "DEVIATING"},
+	})
+
+	require.Equal(t, []string{"CRITICAL"}, target.Attributes["stackstate.health-state"])
+	require.Equal(t, []string{"DEVIATING"}, target.Attributes["stackstate.propagated-health-state"])
+
+	require.Equal(t, []string{"shop", "payments"}, target.Attributes["stackstate.label.team"])
+	require.Equal(t, []string{"prod"}, target.Attributes["stackstate.label.env"])
+	require.Equal(t, []string{"critical"}, target.Attributes["stackstate.tag"])
+	require.Equal(t, []string{"Services"}, target.Attributes["stackstate.layer"])
+	require.Equal(t, []string{"Kubernetes"}, target.Attributes["stackstate.domain"])
+	require.Equal(t, []string{"service"}, target.Attributes["stackstate.component.type"])
+	require.Equal(t, []string{"frontend"}, target.Attributes["stackstate.property.tier"])
+	require.NotContains(t, target.Attributes, "stackstate.property.empty")
+}
+
+func TestToServiceAddsAttributesOfDecodedSnapshot(t *testing.T) {
+	body := `{"viewSnapshotResponse": {
+		"metadata": {"componentTypes": [{"id": 10, "name": "service"}], "layers": [{"id": 20, "name": "Services"}], "domains": [{"id": 30, "name": "Kubernetes"}]},
+		"components": [{
+			"id": 1, "name": "checkout", "type": 10, "layer": 20, "domain": 30,
+			"labels": [{"name": "team:shop"}],
+			"state": {"healthState": "CLEAR"},
+			"properties": {"clusterNameIdentifier": "urn:cluster:/kubernetes:prod", "tier": "frontend", "replicas": 3}
+		}]
+	}}`
+	response, err := decodeSnapshotResponse(strings.NewReader(body))
+	require.NoError(t, err)
+
+	target := toService(response.ViewSnapshotResponse.Components[0])
+
+	require.Equal(t, []string{"service"}, target.Attributes["stackstate.component.type"])
+	require.Equal(t, []string{"Services"}, target.Attributes["stackstate.layer"])
+	require.Equal(t, []string{"Kubernetes"}, target.Attributes["stackstate.domain"])
+	require.Equal(t, []string{"shop"}, target.Attributes["stackstate.label.team"])
+	require.Equal(t, []string{"CLEAR"}, target.Attributes["stackstate.health-state"])
+	require.Equal(t, []string{"frontend"}, target.Attributes["stackstate.property.tier"])
+	require.NotContains(t, target.Attributes, "stackstate.property.replicas")
+	require.NotContains(t, target.Attributes, "stackstate.property.clusterNameIdentifier")
+}
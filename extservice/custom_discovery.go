@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_commons"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_sdk"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-stackstate/config"
+)
+
+// customDiscovery discovers the StackState components matching the STQL query of a user-defined component type
+// (config.ComponentType), e.g. AWS, host or database components.
+type customDiscovery struct {
+	kind      componentKind
+	lastKnown lastKnownTargets
+}
+
+var (
+	_ discovery_kit_sdk.TargetDescriber    = (*customDiscovery)(nil)
+	_ discovery_kit_sdk.AttributeDescriber = (*customDiscovery)(nil)
+)
+
+type QuerySnapshotsApi interface {
+	QuerySnapshots(ctx context.Context, query string) (*resty.Response, ViewSnapshotResponseWrapper, error)
+}
+
+// customKinds returns the component kinds of the user-defined component types in the configuration.
+func customKinds() []componentKind {
+	kinds := make([]componentKind, 0, len(config.Config.ComponentTypes))
+	for _, componentType := range config.Config.ComponentTypes {
+		labelPlural := componentType.LabelPlural
+		if labelPlural == "" {
+			labelPlural = componentType.Label
+		}
+		attributesExcludes := componentType.AttributesExcludes
+		kinds = append(kinds, componentKind{
+			targetType:        fmt.Sprintf("com.steadybit.extension_stackstate.%s", componentType.Id),
+			label:             discovery_kit_api.PluralLabel{One: componentType.Label, Other: labelPlural},
+			idAttribute:       attributeComponentId,
+			urnAttribute:      attributeComponentUrn,
+			nameAttribute:     attributeComponentName,
+			attributeExcludes: func() []string { return attributesExcludes },
+			query:             componentType.Query,
+			attributes:        componentType.Attributes,
+		})
+	}
+	return kinds
+}
+
+func NewCustomDiscoveries() []discovery_kit_sdk.TargetDiscovery {
+	kinds := customKinds()
+	discoveries := make([]discovery_kit_sdk.TargetDiscovery, 0, len(kinds))
+	for _, kind := range kinds {
+		discoveries = append(discoveries, discovery_kit_sdk.NewCachedTargetDiscovery(&customDiscovery{kind: kind},
+			discovery_kit_sdk.WithRefreshTargetsNow(),
+			discovery_kit_sdk.WithRefreshTargetsInterval(context.Background(), config.Config.DiscoveryInterval),
+		))
+	}
+	return discoveries
+}
+
+func (d *customDiscovery) Describe() discovery_kit_api.DiscoveryDescription {
+	return discovery_kit_api.DiscoveryDescription{
+		Id: d.kind.targetType,
+		Discover: discovery_kit_api.DescribingEndpointReferenceWithCallInterval{
+			CallInterval: new(config.Config.DiscoveryInterval.String()),
+		},
+	}
+}
+
+func (d *customDiscovery) DescribeTarget() discovery_kit_api.TargetDescription {
+	columns := []discovery_kit_api.Column{{Attribute: d.kind.nameAttribute}}
+	for _, attribute := range d.mappedAttributes() {
+		columns = append(columns, discovery_kit_api.Column{Attribute: attribute})
+	}
+	return discovery_kit_api.TargetDescription{
+		Id: d.kind.targetType,
+		Label: discovery_kit_api.PluralLabel{
+			One:   fmt.Sprintf("StackState %s", d.kind.label.One),
+			Other: fmt.Sprintf("StackState %s", d.kind.label.Other),
+		},
+		Category: new("monitoring"),
+		Version:  extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:     new(serviceIcon),
+		Table: discovery_kit_api.Table{
+			Columns: columns,
+			OrderBy: []discovery_kit_api.OrderBy{
+				{
+					Attribute: d.kind.nameAttribute,
+					Direction: "ASC",
+				},
+			},
+		},
+	}
+}
+
+// DescribeAttributes only describes the component name, the mapped attributes are named by the user and may already be
+// described by the extension owning them.
+func (d *customDiscovery) DescribeAttributes() []discovery_kit_api.AttributeDescription {
+	return []discovery_kit_api.AttributeDescription{
+		{
+			Attribute: attributeComponentName,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "StackState component",
+				Other: "StackState components",
+			},
+		},
+	}
+}
+
+// mappedAttributes returns the configured target attributes in a stable order.
+func (d *customDiscovery) mappedAttributes() []string {
+	attributes := make([]string, 0, len(d.kind.attributes))
+	for attribute := range d.kind.attributes {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+	return attributes
+}
+
+func (d *customDiscovery) DiscoverTargets(ctx context.Context) ([]discovery_kit_api.Target, error) {
+	return d.lastKnown.keep(getAllCustomComponents(ctx, Client, d.kind))
+}
+
+func getAllCustomComponents(ctx context.Context, api QuerySnapshotsApi, kind componentKind) ([]discovery_kit_api.Target, error) {
+	result := make([]discovery_kit_api.Target, 0, 500)
+	res, stackStateResponse, err := api.QuerySnapshots(ctx, kind.query)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve %s components from StackState: %w", kind.label.One, err)
+	}
+
+	if res.StatusCode() != 200 {
+		return nil, fmt.Errorf("StackState API responded with unexpected status code %d while retrieving %s components", res.StatusCode(), kind.label.One)
+	}
+
+	for _, component := range stackStateResponse.ViewSnapshotResponse.Components {
+		result = append(result, toCustomComponent(component, kind))
+	}
+	return discovery_kit_commons.ApplyAttributeExcludes(result, kind.attributeExcludes()), nil
+}
+
+func toCustomComponent(component Component, kind componentKind) discovery_kit_api.Target {
+	attributes := map[string][]string{
+		attributeComponentId:   {strconv.Itoa(component.Id)},
+		attributeComponentName: {component.Name},
+	}
+	if urn := componentUrn(component); urn != "" {
+		attributes[attributeComponentUrn] = []string{urn}
+	}
+	for attribute, field := range kind.attributes {
+		if values := componentField(component, field); len(values) > 0 {
+			attributes[attribute] = values
+		}
+	}
+	return discovery_kit_api.Target{
+		Id:         targetId(component),
+		Label:      component.Name,
+		TargetType: kind.targetType,
+		Attributes: attributes,
+	}
+}
+
+// componentField returns the values of one of the config.ComponentFields of a component.
+func componentField(component Component, field string) []string {
+	var value string
+	switch field {
+	case "id":
+		value = strconv.Itoa(component.Id)
+	case "name":
+		value = component.Name
+	case "identifiers":
+		return component.Identifiers
+	case "healthState":
+		value = component.State.HealthState
+	case "clusterName":
+		value, _ = clusterAndNamespace(component)
+	case "namespace":
+		_, value = clusterAndNamespace(component)
+	}
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
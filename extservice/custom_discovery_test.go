@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/extension-stackstate/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type querySnapshotsApiMock struct {
+	mock.Mock
+}
+
+func (m *querySnapshotsApiMock) QuerySnapshots(ctx context.Context, query string) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(*resty.Response), args.Get(1).(ViewSnapshotResponseWrapper), args.Error(2)
+}
+
+func TestCustomComponentTypes(t *testing.T) {
+	config.Config.ComponentTypes = config.ComponentTypes{
+		{
+			Id:    "rds-instance",
+			Label: "RDS Instance",
+			Query: `(type = "aws.rds.instance")`,
+			Attributes: map[string]string{
+				"aws.rds.instance.id":      "name",
+				"stackstate.component.urn": "identifiers",
+				"stackstate.namespace":     "namespace",
+				"stackstate.health":        "healthState",
+			},
+			AttributesExcludes: []string{"stackstate.health"},
+		},
+	}
+	defer func() { config.Config.ComponentTypes = nil }()
+
+	kinds := customKinds()
+	require.Len(t, kinds, 1)
+	kind := kinds[0]
+	assert.Equal(t, "com.steadybit.extension_stackstate.rds-instance", kind.targetType)
+	assert.Equal(t, "RDS Instance", kind.label.Other)
+	found, ok := componentKindOf(kind.targetType)
+	require.True(t, ok)
+	assert.Equal(t, kind.targetType, found.targetType)
+	_, ok = componentKindOf("com.steadybit.extension_stackstate.removed")
+	assert.False(t, ok, "component types removed from the configuration are unknown")
+
+	mockedApi := new(querySnapshotsApiMock)
+	mockedApi.On("QuerySnapshots", mock.Anything, `(type = "aws.rds.instance")`).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{
+		ViewSnapshotResponse: ViewSnapshotResponse{
+			Components: []Component{
+				{Id: 5, Name: "orders-db", Identifiers: []string{"urn:aws:rds:orders-db"}, State: State{HealthState: "CLEAR"}},
+			},
+		},
+	}, nil)
+
+	targets, err := getAllCustomComponents(context.Background(), mockedApi, kind)
+	require.NoError(t, err)
+
+	require.Len(t, targets, 1)
+	assert.Equal(t, map[string][]string{
+		"stackstate.component.id":   {"5"},
+		"stackstate.component.name": {"orders-db"},
+		"aws.rds.instance.id":       {"orders-db"},
+		"stackstate.component.urn":  {"urn:aws:rds:orders-db"},
+	}, targets[0].Attributes, "the excluded stackstate.health attribute is missing")
+	assert.Equal(t, "stackstate.component.name", (&customDiscovery{kind: kind}).DescribeAttributes()[0].Attribute)
+
+	description := (&ServiceStatusCheckAction{kind: kind}).Describe()
+	assert.Equal(t, "com.steadybit.extension_stackstate.rds-instance.check", description.Id)
+	assert.Equal(t, `stackstate.component.name=""`, (*description.TargetSelection.SelectionTemplates)[0].Query)
+}
+
+func TestStatusCheckOfRemovedComponentType(t *testing.T) {
+	state := serviceCheckState(statusCheckModeAllTheTime)
+	state.TargetType = "com.steadybit.extension_stackstate.removed"
+
+	_, err := MonitorStatusCheckStatus(context.Background(), &state, new(getSnapshotApiMock))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Target type 'com.steadybit.extension_stackstate.removed' is unknown")
+}
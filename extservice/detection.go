@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+)
+
+var detectionDeadlineParameter = action_kit_api.ActionParameter{
+	Name:         "detectionDeadline",
+	Label:        "Detection deadline",
+	Description:  new("Only used when StackState is expected to detect the failure: the time after the start of the step within which the deviation must be reported."),
+	Type:         action_kit_api.ActionParameterTypeDuration,
+	DefaultValue: new("60s"),
+	Required:     new(false),
+	Order:        new(3),
+}
+
+// detectionDeadline returns the configured deadline for StackState to detect a failure, or the end of the step
+// if no deadline is configured.
+func detectionDeadline(config map[string]any, start time.Time, end time.Time) time.Time {
+	if deadline, ok := config["detectionDeadline"].(float64); ok && deadline > 0 {
+		return start.Add(time.Millisecond * time.Duration(deadline))
+	}
+	return end
+}
+
+// toTimeToDetectMetric reports the time it took StackState to detect a failure in milliseconds.
+func toTimeToDetectMetric(labels map[string]string, timeToDetect time.Duration, now time.Time) *action_kit_api.Metric {
+	return new(action_kit_api.Metric{
+		Name:      new("stackstate_time_to_detect"),
+		Metric:    labels,
+		Timestamp: now,
+		Value:     float64(timeToDetect.Milliseconds()),
+	})
+}
+
+// toTimeToRecoverMetric reports the time it took a component to recover from a deviation in milliseconds.
+func toTimeToRecoverMetric(labels map[string]string, timeToRecover time.Duration, now time.Time) *action_kit_api.Metric {
+	return new(action_kit_api.Metric{
+		Name:      new("stackstate_time_to_recover"),
+		Metric:    labels,
+		Timestamp: now,
+		Value:     float64(timeToRecover.Milliseconds()),
+	})
+}
+
+func timeToDetectSummary(subject string, timeToDetect time.Duration) *action_kit_api.Summary {
+	return new(action_kit_api.Summary{
+		Level: action_kit_api.SummaryLevelInfo,
+		Text:  fmt.Sprintf("StackState detected the failure of %s after %s.", subject, timeToDetect.Round(time.Second)),
+	})
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/steadybit/extension-stackstate/config"
+)
+
+// inDiscoveryScope applies the configured cluster and namespace include and exclude rules to a discovered component.
+func inDiscoveryScope(component Component) bool {
+	clusterName, namespace := clusterAndNamespace(component)
+	return isIncluded(config.Config.DiscoveryIncludeClusters, config.Config.DiscoveryExcludeClusters, clusterName) &&
+		isIncluded(config.Config.DiscoveryIncludeNamespaces, config.Config.DiscoveryExcludeNamespaces, namespace)
+}
+
+func isIncluded(includes config.NamePatterns, excludes config.NamePatterns, name string) bool {
+	return (len(includes) == 0 || includes.Matches(name)) && !excludes.Matches(name)
+}
+
+// discoveryScopeStql pushes the include and exclude rules without wildcards into STQL, so StackState doesn't return
+// components that would be discarded anyway. Rules with wildcards or regular expressions are only applied by
+// inDiscoveryScope. An empty string is returned if there is nothing to push down.
+func discoveryScopeStql() string {
+	var filters []string
+	filters = append(filters, scopeStql("cluster-name:", config.Config.DiscoveryIncludeClusters, config.Config.DiscoveryExcludeClusters)...)
+	filters = append(filters, scopeStql("namespace:", config.Config.DiscoveryIncludeNamespaces, config.Config.DiscoveryExcludeNamespaces)...)
+	return strings.Join(filters, " AND ")
+}
+
+func scopeStql(labelPrefix string, includes config.NamePatterns, excludes config.NamePatterns) []string {
+	var filters []string
+	// The includes can only be pushed down together, a single wildcard include would otherwise be dropped.
+	if labels, ok := exactLabels(labelPrefix, includes); ok && len(labels) > 0 {
+		filters = append(filters, fmt.Sprintf("label in (%s)", strings.Join(labels, ", ")))
+	}
+	var excludedLabels []string
+	for _, exclude := range excludes {
+		if name, ok := exclude.Exact(); ok {
+			excludedLabels = append(excludedLabels, stqlString(labelPrefix+name))
+		}
+	}
+	if len(excludedLabels) > 0 {
+		filters = append(filters, fmt.Sprintf("NOT label in (%s)", strings.Join(excludedLabels, ", ")))
+	}
+	return filters
+}
+
+func exactLabels(labelPrefix string, patterns config.NamePatterns) ([]string, bool) {
+	labels := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		name, ok := pattern.Exact()
+		if !ok {
+			return nil, false
+		}
+		labels = append(labels, stqlString(labelPrefix+name))
+	}
+	return labels, true
+}
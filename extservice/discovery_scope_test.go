@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/steadybit/extension-stackstate/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func namePatterns(t *testing.T, value string) config.NamePatterns {
+	var patterns config.NamePatterns
+	require.NoError(t, patterns.Decode(value))
+	return patterns
+}
+
+func componentIn(id int, clusterName string, namespace string) Component {
+	return Component{
+		Id:   id,
+		Name: "checkout",
+		Properties: Properties{
+			ClusterNameIdentifier: "urn:cluster:/kubernetes:" + clusterName,
+			NamespaceIdentifier:   "urn:kubernetes:/" + clusterName + ":namespace/" + namespace,
+		},
+	}
+}
+
+func TestDiscoveryScope(t *testing.T) {
+	t.Cleanup(func() {
+		config.Config.DiscoveryIncludeClusters = nil
+		config.Config.DiscoveryExcludeClusters = nil
+		config.Config.DiscoveryIncludeNamespaces = nil
+		config.Config.DiscoveryExcludeNamespaces = nil
+	})
+	config.Config.DiscoveryIncludeClusters = namePatterns(t, "prod-*,/^stage-[0-9]+$/")
+	config.Config.DiscoveryExcludeClusters = namePatterns(t, "prod-legacy")
+	config.Config.DiscoveryExcludeNamespaces = namePatterns(t, "kube-system, *-sandbox")
+
+	assert.True(t, inDiscoveryScope(componentIn(1, "prod-eu", "shop")))
+	assert.True(t, inDiscoveryScope(componentIn(2, "stage-1", "shop")))
+	assert.False(t, inDiscoveryScope(componentIn(3, "stage-x", "shop")))
+	assert.False(t, inDiscoveryScope(componentIn(4, "prod-legacy", "shop")))
+	assert.False(t, inDiscoveryScope(componentIn(5, "prod-eu", "kube-system")))
+	assert.False(t, inDiscoveryScope(componentIn(6, "prod-eu", "team-sandbox")))
+
+	// Wildcard includes can't be pushed down, exact excludes can.
+	assert.Equal(t, `NOT label in ("cluster-name:prod-legacy") AND NOT label in ("namespace:kube-system")`, discoveryScopeStql())
+
+	t.Run("exact includes are pushed down", func(t *testing.T) {
+		config.Config.DiscoveryIncludeClusters = namePatterns(t, "prod,stage")
+		config.Config.DiscoveryExcludeClusters = nil
+		config.Config.DiscoveryExcludeNamespaces = nil
+
+		assert.Equal(t, `label in ("cluster-name:prod", "cluster-name:stage")`, discoveryScopeStql())
+	})
+
+	t.Run("discovery drops components out of scope", func(t *testing.T) {
+		config.Config.DiscoveryIncludeClusters = nil
+		config.Config.DiscoveryExcludeNamespaces = namePatterns(t, "*-sandbox")
+		mockedApi := new(getComponentSnapshotsApiMock)
+		mockedApi.On("GetComponentSnapshots", mock.Anything, "deployment").Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{
+			ViewSnapshotResponse: ViewSnapshotResponse{
+				Components: []Component{componentIn(1, "prod", "shop"), componentIn(2, "prod", "team-sandbox")},
+			},
+		}, nil)
+
+		targets, err := getAllWorkloads(context.Background(), mockedApi, deploymentKind)
+
+		require.NoError(t, err)
+		require.Len(t, targets, 1)
+		assert.Equal(t, "1", targets[0].Id)
+	})
+}
+
+func TestNamePatternsDecode(t *testing.T) {
+	var patterns config.NamePatterns
+	assert.Error(t, patterns.Decode("/[/"))
+	assert.Error(t, patterns.Decode("[a"))
+	require.NoError(t, patterns.Decode(""))
+	assert.Empty(t, patterns)
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"fmt"
+
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	"github.com/steadybit/extension-kit/extbuild"
+)
+
+const (
+	kubernetesDeploymentTargetType  = "com.steadybit.extension_kubernetes.kubernetes-deployment"
+	kubernetesStatefulSetTargetType = "com.steadybit.extension_kubernetes.kubernetes-statefulset"
+	kubernetesDaemonSetTargetType   = "com.steadybit.extension_kubernetes.kubernetes-daemonset"
+	kubernetesPodTargetType         = "com.steadybit.extension_kubernetes.kubernetes-pod"
+	containerTargetType             = "com.steadybit.extension_container.container"
+)
+
+// enrichmentRules copies the StackState ids of a component to the targets of the Kubernetes and container extensions
+// with the same cluster, namespace and name, so a check can be aimed at the same target as the attack. Only the
+// workload kinds copy the health states; the services would otherwise mix their health states into the same
+// deployments and containers.
+func enrichmentRules(kind componentKind) []discovery_kit_api.TargetEnrichmentRule {
+	attributes := []discovery_kit_api.Attribute{
+		{Matcher: discovery_kit_api.Equals, Name: kind.idAttribute},
+		{Matcher: discovery_kit_api.Equals, Name: kind.urnAttribute},
+	}
+	if kind.idAttribute != attributeServiceId {
+		attributes = append(attributes,
+			discovery_kit_api.Attribute{Matcher: discovery_kit_api.Equals, Name: attributeHealthState},
+			discovery_kit_api.Attribute{Matcher: discovery_kit_api.Equals, Name: attributePropagatedHealthState},
+		)
+	}
+
+	rules := make([]discovery_kit_api.TargetEnrichmentRule, 0, len(kind.enrichedTargetTypes))
+	for _, targetType := range kind.enrichedTargetTypes {
+		rules = append(rules, discovery_kit_api.TargetEnrichmentRule{
+			Id:      fmt.Sprintf("%s-to-%s", kind.targetType, targetType),
+			Version: extbuild.GetSemverVersionStringOrUnknown(),
+			Src: discovery_kit_api.SourceOrDestination{
+				Type:     kind.targetType,
+				Selector: enrichmentSelector(kind, "dest"),
+			},
+			Dest: discovery_kit_api.SourceOrDestination{
+				Type:     targetType,
+				Selector: enrichmentSelector(kind, "src"),
+			},
+			Attributes: attributes,
+		})
+	}
+	return rules
+}
+
+// enrichmentSelector matches the cluster, namespace and name attributes of the other side of the rule.
+func enrichmentSelector(kind componentKind, other string) map[string]string {
+	selector := make(map[string]string, 3)
+	for _, attribute := range []string{attributeK8ClusterName, attributeK8Namespace, kind.nameAttribute} {
+		selector[attribute] = fmt.Sprintf("${%s.%s}", other, attribute)
+	}
+	return selector
+}
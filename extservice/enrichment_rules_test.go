@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"testing"
+
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrichmentRulesMatchKubernetesTargetsByClusterNamespaceAndName(t *testing.T) {
+	rules := (&workloadDiscovery{kind: deploymentKind}).DescribeEnrichmentRules()
+
+	assert.Len(t, rules, 2)
+	rule := rules[0]
+	assert.Equal(t, "com.steadybit.extension_stackstate.deployment-to-com.steadybit.extension_kubernetes.kubernetes-deployment", rule.Id)
+	assert.Equal(t, deploymentTargetType, rule.Src.Type)
+	assert.Equal(t, map[string]string{
+		"k8s.cluster-name": "${dest.k8s.cluster-name}",
+		"k8s.namespace":    "${dest.k8s.namespace}",
+		"k8s.deployment":   "${dest.k8s.deployment}",
+	}, rule.Src.Selector)
+	assert.Equal(t, kubernetesDeploymentTargetType, rule.Dest.Type)
+	assert.Equal(t, map[string]string{
+		"k8s.cluster-name": "${src.k8s.cluster-name}",
+		"k8s.namespace":    "${src.k8s.namespace}",
+		"k8s.deployment":   "${src.k8s.deployment}",
+	}, rule.Dest.Selector)
+	assert.Equal(t, []discovery_kit_api.Attribute{
+		{Matcher: discovery_kit_api.Equals, Name: "stackstate.component.id"},
+		{Matcher: discovery_kit_api.Equals, Name: "stackstate.component.urn"},
+		{Matcher: discovery_kit_api.Equals, Name: "stackstate.health-state"},
+		{Matcher: discovery_kit_api.Equals, Name: "stackstate.propagated-health-state"},
+	}, rule.Attributes)
+	assert.Equal(t, containerTargetType, rules[1].Dest.Type)
+}
+
+func TestServiceEnrichmentRulesOnlyCopyIds(t *testing.T) {
+	rules := (&serviceDiscovery{}).DescribeEnrichmentRules()
+
+	assert.Len(t, rules, 2)
+	for _, rule := range rules {
+		assert.Equal(t, "${dest.k8s.service.name}", rule.Src.Selector["k8s.service.name"])
+		assert.Equal(t, []discovery_kit_api.Attribute{
+			{Matcher: discovery_kit_api.Equals, Name: "stackstate.service.id"},
+			{Matcher: discovery_kit_api.Equals, Name: "stackstate.service.urn"},
+		}, rule.Attributes)
+	}
+}
+
+func TestPodEnrichmentRulesDontEnrichContainers(t *testing.T) {
+	rules := (&workloadDiscovery{kind: podKind}).DescribeEnrichmentRules()
+
+	assert.Len(t, rules, 1)
+	assert.Equal(t, kubernetesPodTargetType, rules[0].Dest.Type)
+	assert.Equal(t, "${src.k8s.pod.name}", rules[0].Dest.Selector["k8s.pod.name"])
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/extension-kit/extutil"
+)
+
+type GetHealthHistoryApi interface {
+	GetHealthHistory(ctx context.Context, componentId string, start time.Time, end time.Time) (*resty.Response, HealthHistoryResponse, error)
+}
+
+// healthStateSegment is a period of the step during which the component had one health state.
+type healthStateSegment struct {
+	healthState string
+	start       time.Time
+	end         time.Time
+}
+
+// evaluateHealthHistory re-evaluates the 'All the time' and 'At least once' modes at the end of the step against the
+// health state changes recorded by StackState. This catches short deviations between two polls and uses StackState's
+// timestamps instead of the extension's clock. If the history can't be retrieved, the sampled result is kept.
+func evaluateHealthHistory(ctx context.Context, state *ServiceStatusCheckState, result *action_kit_api.StatusResult, api GetHealthHistoryApi) *action_kit_api.StatusResult {
+	if !result.Completed || len(state.ExpectedStatus) == 0 {
+		return result
+	}
+	if state.StatusCheckMode != statusCheckModeAllTheTime && state.StatusCheckMode != statusCheckModeAtLeastOnce {
+		return result
+	}
+
+	res, history, err := api.GetHealthHistory(ctx, state.ServiceId, state.Start, state.End)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed to retrieve health history for component %s, using the polled health states.", state.ServiceId)
+		return result
+	}
+	if !res.IsSuccess() {
+		log.Warn().Msgf("StackState API responded with unexpected status code %d while retrieving health history for component %s, using the polled health states.", res.StatusCode(), state.ServiceId)
+		return result
+	}
+	segments := toHealthStateSegments(history.HealthStateChanges, state.Start, state.End)
+	if len(segments) == 0 {
+		return result
+	}
+
+	kind, ok := componentKindOf(state.TargetType)
+	if !ok {
+		return result
+	}
+	if state.StatusCheckMode == statusCheckModeAllTheTime && result.Error == nil {
+		var deviationStart time.Time
+		for _, segment := range segments {
+			if state.isExpectedStatus(segment.healthState) {
+				deviationStart = time.Time{}
+				continue
+			}
+			if deviationStart.IsZero() {
+				deviationStart = segment.start
+			}
+			if segment.end.Sub(deviationStart) >= state.GracePeriod {
+				result.Error = new(action_kit_api.ActionKitError{
+					Title: fmt.Sprintf("%s '%s' (id %s) had status '%s' at %s whereas %s is expected.",
+						kind.label.One,
+						state.ServiceName,
+						state.ServiceId,
+						segment.healthState,
+						segment.start.UTC().Format(time.RFC3339),
+						state.expectedStatusText()),
+					Status: extutil.Ptr(action_kit_api.Failed),
+				})
+				break
+			}
+		}
+	} else if state.StatusCheckMode == statusCheckModeAtLeastOnce && state.ErrorKind == errorKindAtLeastOnce {
+		// Only the at-least-once verdict is replaced, the flap detection still applies.
+		for _, segment := range segments {
+			if state.isExpectedStatus(segment.healthState) {
+				state.StatusCheckSuccess = true
+				state.ErrorKind = ""
+				result.Error = transitionsError(state, kind, state.ServiceName)
+				if result.Error != nil {
+					state.ErrorKind = errorKindTransitions
+				}
+				break
+			}
+		}
+	}
+	return result
+}
+
+// toHealthStateSegments turns the recorded changes into the health states the component had within [start, end].
+func toHealthStateSegments(changes []HealthStateChange, start time.Time, end time.Time) []healthStateSegment {
+	if len(changes) == 0 {
+		return nil
+	}
+	segments := []healthStateSegment{{healthState: changes[0].OldHealthState, start: start}}
+	for _, change := range changes {
+		timestamp := time.UnixMilli(change.Timestamp)
+		if !timestamp.After(start) {
+			segments[len(segments)-1].healthState = change.NewHealthState
+			continue
+		}
+		if timestamp.After(end) {
+			break
+		}
+		segments[len(segments)-1].end = timestamp
+		segments = append(segments, healthStateSegment{healthState: change.NewHealthState, start: timestamp})
+	}
+	segments[len(segments)-1].end = end
+	return segments
+}
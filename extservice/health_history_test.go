@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/extension-kit/extutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type getHealthHistoryApiMock struct {
+	mock.Mock
+}
+
+func (m *getHealthHistoryApiMock) GetHealthHistory(ctx context.Context, componentId string, start time.Time, end time.Time) (*resty.Response, HealthHistoryResponse, error) {
+	args := m.Called(ctx, componentId, start, end)
+	return args.Get(0).(*resty.Response), args.Get(1).(HealthHistoryResponse), args.Error(2)
+}
+
+func TestEvaluateHealthHistory(t *testing.T) {
+	start := time.UnixMilli(1_700_000_000_000)
+	end := start.Add(time.Minute)
+	historyState := func(mode string) ServiceStatusCheckState {
+		state := serviceCheckState(mode)
+		state.Start = start
+		state.End = end
+		return state
+	}
+	historyApi := func(status int, changes ...HealthStateChange) *getHealthHistoryApiMock {
+		mockedApi := new(getHealthHistoryApiMock)
+		mockedApi.On("GetHealthHistory", mock.Anything, "123", start, end).Return(apiResponseWithStatus(status), HealthHistoryResponse{HealthStateChanges: changes}, nil)
+		return mockedApi
+	}
+	blip := []HealthStateChange{
+		{Timestamp: start.Add(10 * time.Second).UnixMilli(), OldHealthState: "CLEAR", NewHealthState: "CRITICAL"},
+		{Timestamp: start.Add(12 * time.Second).UnixMilli(), OldHealthState: "CRITICAL", NewHealthState: "CLEAR"},
+	}
+
+	t.Run("allTheTime fails on a deviation between polls", func(t *testing.T) {
+		state := historyState(statusCheckModeAllTheTime)
+
+		result := evaluateHealthHistory(context.TODO(), &state, &action_kit_api.StatusResult{Completed: true}, historyApi(200, blip...))
+
+		require.NotNil(t, result.Error)
+		require.Equal(t, action_kit_api.Failed, *result.Error.Status)
+		require.Equal(t, "Service 'test' (id 123) had status 'CRITICAL' at 2023-11-14T22:13:30Z whereas 'CLEAR' is expected.", result.Error.Title)
+	})
+
+	t.Run("allTheTime respects the grace period", func(t *testing.T) {
+		state := historyState(statusCheckModeAllTheTime)
+		state.GracePeriod = 5 * time.Second
+
+		result := evaluateHealthHistory(context.TODO(), &state, &action_kit_api.StatusResult{Completed: true}, historyApi(200, blip...))
+
+		require.Nil(t, result.Error)
+	})
+
+	t.Run("atLeastOnce succeeds on an expected status between polls", func(t *testing.T) {
+		state := historyState(statusCheckModeAtLeastOnce)
+		state.ExpectedStatus = []string{"CRITICAL"}
+		state.ErrorKind = errorKindAtLeastOnce
+		sampled := &action_kit_api.StatusResult{Completed: true, Error: new(action_kit_api.ActionKitError{Title: "sampled", Status: extutil.Ptr(action_kit_api.Failed)})}
+
+		result := evaluateHealthHistory(context.TODO(), &state, sampled, historyApi(200, blip...))
+
+		require.Nil(t, result.Error)
+	})
+
+	t.Run("atLeastOnce keeps the flap error on an expected status between polls", func(t *testing.T) {
+		state := historyState(statusCheckModeAtLeastOnce)
+		state.ExpectedStatus = []string{"CRITICAL"}
+		state.ErrorKind = errorKindAtLeastOnce
+		state.MaxTransitions = 1
+		state.Transitions = []HealthStateTransition{{From: "CLEAR", To: "DEVIATING"}, {From: "DEVIATING", To: "CLEAR"}}
+		sampled := &action_kit_api.StatusResult{Completed: true, Error: new(action_kit_api.ActionKitError{Title: "sampled", Status: extutil.Ptr(action_kit_api.Failed)})}
+
+		result := evaluateHealthHistory(context.TODO(), &state, sampled, historyApi(200, blip...))
+
+		require.NotNil(t, result.Error)
+		require.Equal(t, "Service 'test' (id 123) changed its health state 2 times whereas at most 1 changes are allowed.", result.Error.Title)
+	})
+
+	t.Run("atLeastOnce keeps other errors", func(t *testing.T) {
+		state := historyState(statusCheckModeAtLeastOnce)
+		state.ExpectedStatus = []string{"CRITICAL"}
+		state.ErrorKind = errorKindMissingComponent
+		sampled := &action_kit_api.StatusResult{Completed: true, Error: new(action_kit_api.ActionKitError{Title: "missing", Status: extutil.Ptr(action_kit_api.Failed)})}
+
+		result := evaluateHealthHistory(context.TODO(), &state, sampled, historyApi(200, blip...))
+
+		require.Equal(t, "missing", result.Error.Title)
+	})
+
+	t.Run("keeps the sampled result if the history is unavailable", func(t *testing.T) {
+		state := historyState(statusCheckModeAtLeastOnce)
+		sampled := &action_kit_api.StatusResult{Completed: true, Error: new(action_kit_api.ActionKitError{Title: "sampled", Status: extutil.Ptr(action_kit_api.Failed)})}
+		mockedApi := new(getHealthHistoryApiMock)
+		mockedApi.On("GetHealthHistory", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(apiResponseWithStatus(500), HealthHistoryResponse{}, errors.New("boom"))
+
+		result := evaluateHealthHistory(context.TODO(), &state, sampled, mockedApi)
+
+		require.Equal(t, "sampled", result.Error.Title)
+	})
+
+	t.Run("is skipped before the end of the step", func(t *testing.T) {
+		state := historyState(statusCheckModeAllTheTime)
+		mockedApi := new(getHealthHistoryApiMock)
+
+		result := evaluateHealthHistory(context.TODO(), &state, &action_kit_api.StatusResult{Completed: false}, mockedApi)
+
+		require.Nil(t, result.Error)
+		mockedApi.AssertNotCalled(t, "GetHealthHistory", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestToHealthStateSegments(t *testing.T) {
+	start := time.UnixMilli(1_700_000_000_000)
+	end := start.Add(time.Minute)
+
+	segments := toHealthStateSegments([]HealthStateChange{
+		{Timestamp: start.Add(-time.Minute).UnixMilli(), OldHealthState: "CLEAR", NewHealthState: "DEVIATING"},
+		{Timestamp: start.Add(20 * time.Second).UnixMilli(), OldHealthState: "DEVIATING", NewHealthState: "CLEAR"},
+		{Timestamp: end.Add(time.Second).UnixMilli(), OldHealthState: "CLEAR", NewHealthState: "CRITICAL"},
+	}, start, end)
+
+	require.Equal(t, []healthStateSegment{
+		{healthState: "DEVIATING", start: start, end: start.Add(20 * time.Second)},
+		{healthState: "CLEAR", start: start.Add(20 * time.Second), end: end},
+	}, segments)
+}
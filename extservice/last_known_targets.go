@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	"github.com/steadybit/extension-stackstate/config"
+)
+
+// lastKnownTargets bridges failing discoveries with the last discovered targets, so a StackState hiccup doesn't make
+// all targets vanish. Once the targets are older than the configured staleness limit, the error is passed on.
+type lastKnownTargets struct {
+	mu           sync.Mutex
+	targets      []discovery_kit_api.Target
+	discoveredAt time.Time
+}
+
+func (l *lastKnownTargets) keep(targets []discovery_kit_api.Target, err error) ([]discovery_kit_api.Target, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if err == nil {
+		l.targets = targets
+		l.discoveredAt = now
+		return targets, nil
+	}
+	if l.discoveredAt.IsZero() || now.Sub(l.discoveredAt) > config.Config.DiscoveryStalenessLimit {
+		return nil, err
+	}
+	log.Warn().Err(err).Msgf("Discovery failed, keeping the %d targets discovered %s ago.", len(l.targets), now.Sub(l.discoveredAt).Round(time.Second))
+	return l.targets, nil
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	"github.com/steadybit/extension-stackstate/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastKnownTargets(t *testing.T) {
+	stalenessLimit := config.Config.DiscoveryStalenessLimit
+	t.Cleanup(func() { config.Config.DiscoveryStalenessLimit = stalenessLimit })
+	config.Config.DiscoveryStalenessLimit = time.Minute
+	failure := errors.New("StackState is down")
+	discovered := []discovery_kit_api.Target{{Id: "1"}}
+
+	t.Run("passes the error without previous targets", func(t *testing.T) {
+		var lastKnown lastKnownTargets
+
+		targets, err := lastKnown.keep(nil, failure)
+
+		assert.Nil(t, targets)
+		assert.ErrorIs(t, err, failure)
+	})
+
+	t.Run("keeps the previous targets within the staleness limit", func(t *testing.T) {
+		var lastKnown lastKnownTargets
+		_, err := lastKnown.keep(discovered, nil)
+		require.NoError(t, err)
+
+		targets, err := lastKnown.keep(nil, failure)
+
+		assert.NoError(t, err)
+		assert.Equal(t, discovered, targets)
+	})
+
+	t.Run("passes the error once the targets are stale", func(t *testing.T) {
+		var lastKnown lastKnownTargets
+		_, err := lastKnown.keep(discovered, nil)
+		require.NoError(t, err)
+		lastKnown.discoveredAt = time.Now().Add(-2 * time.Minute)
+
+		targets, err := lastKnown.keep(nil, failure)
+
+		assert.Nil(t, targets)
+		assert.ErrorIs(t, err, failure)
+	})
+}
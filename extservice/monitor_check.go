@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/action-kit/go/action_kit_sdk"
+	extension_kit "github.com/steadybit/extension-kit"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-kit/extutil"
+	"github.com/steadybit/extension-stackstate/config"
+)
+
+const (
+	monitorCheckModeStaysQuiet = "staysQuiet"
+	monitorCheckModeFires      = "fires"
+	// monitorCheckModeFiresWithin expects the monitor to fire within the detection deadline.
+	monitorCheckModeFiresWithin = "firesWithin"
+)
+
+type MonitorStatusCheckAction struct{}
+
+// Make sure action implements all required interfaces
+var (
+	_ action_kit_sdk.Action[MonitorStatusCheckState]           = (*MonitorStatusCheckAction)(nil)
+	_ action_kit_sdk.ActionWithStatus[MonitorStatusCheckState] = (*MonitorStatusCheckAction)(nil)
+)
+
+type MonitorStatusCheckState struct {
+	MonitorId        string
+	MonitorName      string
+	End              time.Time
+	MonitorCheckMode string
+	// Fired remembers whether the monitor reported a DEVIATING or CRITICAL health state during the step.
+	Fired             bool
+	Start             time.Time
+	DetectionDeadline time.Time
+	TimeToDetect      time.Duration
+}
+
+type GetMonitorStatusApi interface {
+	GetMonitorStatus(ctx context.Context, monitorId string) (*resty.Response, MonitorStatusResponse, error)
+}
+
+func NewMonitorStatusCheckAction() action_kit_sdk.Action[MonitorStatusCheckState] {
+	return &MonitorStatusCheckAction{}
+}
+
+func (m *MonitorStatusCheckAction) NewEmptyState() MonitorStatusCheckState {
+	return MonitorStatusCheckState{}
+}
+
+func (m *MonitorStatusCheckAction) Describe() action_kit_api.ActionDescription {
+	return action_kit_api.ActionDescription{
+		Id:          fmt.Sprintf("%s.check", monitorTargetType),
+		Label:       "StackState Monitor",
+		Description: "collects the health states reported by a monitor and verifies whether the monitor fires or stays quiet.",
+		Version:     extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:        new(serviceIcon),
+		TargetSelection: new(action_kit_api.TargetSelection{
+			TargetType:          monitorTargetType,
+			QuantityRestriction: extutil.Ptr(action_kit_api.QuantityRestrictionAll),
+			SelectionTemplates: new([]action_kit_api.TargetSelectionTemplate{
+				{
+					Label:       "monitor name",
+					Description: new("Find monitor by name"),
+					Query:       "stackstate.monitor.name=\"\"",
+				},
+			}),
+		}),
+		Technology: new("StackState"),
+
+		Kind:        action_kit_api.Check,
+		TimeControl: action_kit_api.TimeControlInternal,
+		Parameters: []action_kit_api.ActionParameter{
+			{
+				Name:         "duration",
+				Label:        "Duration",
+				Description:  new(""),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				DefaultValue: new("30s"),
+				Order:        new(1),
+				Required:     new(true),
+			},
+			{
+				Name:         "monitorCheckMode",
+				Label:        "Expectation",
+				Description:  new("Should the monitor stay quiet or fire during the step?"),
+				Type:         action_kit_api.ActionParameterTypeString,
+				DefaultValue: new(monitorCheckModeStaysQuiet),
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ExplicitParameterOption{
+						Label: "Stays quiet",
+						Value: monitorCheckModeStaysQuiet,
+					},
+					action_kit_api.ExplicitParameterOption{
+						Label: "Fires at least once",
+						Value: monitorCheckModeFires,
+					},
+					action_kit_api.ExplicitParameterOption{
+						Label: "Fires within deadline",
+						Value: monitorCheckModeFiresWithin,
+					},
+				}),
+				Required: new(true),
+				Order:    new(2),
+			},
+			detectionDeadlineParameter,
+		},
+		Widgets: new([]action_kit_api.Widget{
+			action_kit_api.StateOverTimeWidget{
+				Type:  action_kit_api.ComSteadybitWidgetStateOverTime,
+				Title: "StackState Monitor Status",
+				Identity: action_kit_api.StateOverTimeWidgetIdentityConfig{
+					From: attributeMonitorId,
+				},
+				Label: action_kit_api.StateOverTimeWidgetLabelConfig{
+					From: attributeMonitorName,
+				},
+				State: action_kit_api.StateOverTimeWidgetStateConfig{
+					From: attributeState,
+				},
+				Tooltip: action_kit_api.StateOverTimeWidgetTooltipConfig{
+					From: attributeTooltip,
+				},
+				Url: new(action_kit_api.StateOverTimeWidgetUrlConfig{
+					From: new(attributeUrl),
+				}),
+				Value: new(action_kit_api.StateOverTimeWidgetValueConfig{
+					Hide: new(true),
+				}),
+			},
+		}),
+		Status: new(action_kit_api.MutatingEndpointReferenceWithCallInterval{
+			CallInterval: new("1s"),
+		}),
+	}
+}
+
+func (m *MonitorStatusCheckAction) Prepare(_ context.Context, state *MonitorStatusCheckState, request action_kit_api.PrepareActionRequestBody) (*action_kit_api.PrepareResult, error) {
+	monitorId := request.Target.Attributes[attributeMonitorId]
+	if len(monitorId) == 0 {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Target is missing the '%s' attribute.", attributeMonitorId), nil))
+	}
+
+	duration := request.Config["duration"].(float64)
+
+	state.MonitorId = monitorId[0]
+	if monitorName := request.Target.Attributes[attributeMonitorName]; len(monitorName) > 0 {
+		state.MonitorName = monitorName[0]
+	}
+	state.Start = time.Now()
+	state.End = state.Start.Add(time.Millisecond * time.Duration(duration))
+	state.DetectionDeadline = detectionDeadline(request.Config, state.Start, state.End)
+	state.MonitorCheckMode = monitorCheckModeStaysQuiet
+	if request.Config["monitorCheckMode"] != nil {
+		state.MonitorCheckMode = fmt.Sprintf("%v", request.Config["monitorCheckMode"])
+	}
+	return nil, nil
+}
+
+func (m *MonitorStatusCheckAction) Start(_ context.Context, _ *MonitorStatusCheckState) (*action_kit_api.StartResult, error) {
+	return nil, nil
+}
+
+func (m *MonitorStatusCheckAction) Status(ctx context.Context, state *MonitorStatusCheckState) (*action_kit_api.StatusResult, error) {
+	return CheckMonitorStatus(ctx, state, Client)
+}
+
+func CheckMonitorStatus(ctx context.Context, state *MonitorStatusCheckState, api GetMonitorStatusApi) (*action_kit_api.StatusResult, error) {
+	now := time.Now()
+	res, monitorStatus, err := api.GetMonitorStatus(ctx, state.MonitorId)
+	if err != nil {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to retrieve the status of monitor %s from StackState.", state.MonitorId), err))
+	}
+	if !res.IsSuccess() {
+		return nil, new(extension_kit.ToError(fmt.Sprintf("StackState API responded with unexpected status code %d while retrieving the status of monitor %s.", res.StatusCode(), state.MonitorId), nil))
+	}
+	completed := now.After(state.End)
+
+	counts := monitorStatus.MonitorHealthStateStateCounts
+	firing := counts.DeviatingCount > 0 || counts.CriticalCount > 0
+	metrics := []action_kit_api.Metric{*toMonitorMetric(state, counts, now)}
+	if firing && !state.Fired {
+		state.Fired = true
+		state.TimeToDetect = now.Sub(state.Start)
+		if state.MonitorCheckMode == monitorCheckModeFiresWithin {
+			metrics = append(metrics, *toTimeToDetectMetric(map[string]string{
+				attributeMonitorId:   state.MonitorId,
+				attributeMonitorName: state.MonitorName,
+			}, state.TimeToDetect, now))
+		}
+	}
+
+	var checkError *action_kit_api.ActionKitError
+	var summary *action_kit_api.Summary
+	if state.MonitorCheckMode == monitorCheckModeStaysQuiet && firing {
+		checkError = new(action_kit_api.ActionKitError{
+			Title: fmt.Sprintf("Monitor '%s' (id %s) fires with %d CRITICAL and %d DEVIATING health states whereas it is expected to stay quiet.",
+				state.MonitorName,
+				state.MonitorId,
+				counts.CriticalCount,
+				counts.DeviatingCount),
+			Status: extutil.Ptr(action_kit_api.Failed),
+		})
+	} else if state.MonitorCheckMode == monitorCheckModeFires && completed && !state.Fired {
+		checkError = new(action_kit_api.ActionKitError{
+			Title: fmt.Sprintf("Monitor '%s' (id %s) didn't fire.",
+				state.MonitorName,
+				state.MonitorId),
+			Status: extutil.Ptr(action_kit_api.Failed),
+		})
+	} else if state.MonitorCheckMode == monitorCheckModeFiresWithin {
+		if state.Fired {
+			summary = timeToDetectSummary(fmt.Sprintf("monitor '%s'", state.MonitorName), state.TimeToDetect)
+		} else if now.After(state.DetectionDeadline) || completed {
+			checkError = new(action_kit_api.ActionKitError{
+				Title: fmt.Sprintf("Monitor '%s' (id %s) didn't fire within %s.",
+					state.MonitorName,
+					state.MonitorId,
+					state.DetectionDeadline.Sub(state.Start).Round(time.Second)),
+				Status: extutil.Ptr(action_kit_api.Failed),
+			})
+		}
+	}
+
+	return &action_kit_api.StatusResult{
+		Completed: completed,
+		Error:     checkError,
+		Metrics:   &metrics,
+		Summary:   summary,
+	}, nil
+}
+
+func toMonitorMetric(state *MonitorStatusCheckState, counts HealthStateCounts, now time.Time) *action_kit_api.Metric {
+	metricState := "success"
+	if counts.CriticalCount > 0 {
+		metricState = "danger"
+	} else if counts.DeviatingCount > 0 {
+		metricState = "warn"
+	}
+
+	uiBaseUrl := config.Config.ApiBaseUrl
+	if len(uiBaseUrl) >= 3 {
+		uiBaseUrl = uiBaseUrl[:len(uiBaseUrl)-3]
+	}
+
+	return new(action_kit_api.Metric{
+		Name: new("stackstate_monitor_status"),
+		Metric: map[string]string{
+			attributeMonitorId:   state.MonitorId,
+			attributeMonitorName: state.MonitorName,
+			attributeState:       metricState,
+			attributeTooltip: fmt.Sprintf("Monitor health states: %d CRITICAL, %d DEVIATING, %d CLEAR",
+				counts.CriticalCount,
+				counts.DeviatingCount,
+				counts.ClearCount),
+			attributeUrl: fmt.Sprintf("%s/#/monitors/%s", uiBaseUrl, url.PathEscape(state.MonitorId)),
+		},
+		Timestamp: now,
+		Value:     0,
+	})
+}
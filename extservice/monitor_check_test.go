@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type getMonitorStatusApiMock struct {
+	mock.Mock
+}
+
+func (m *getMonitorStatusApiMock) GetMonitorStatus(ctx context.Context, monitorId string) (*resty.Response, MonitorStatusResponse, error) {
+	args := m.Called(ctx, monitorId)
+	return args.Get(0).(*resty.Response), args.Get(1).(MonitorStatusResponse), args.Error(2)
+}
+
+type getMonitorsApiMock struct {
+	mock.Mock
+}
+
+func (m *getMonitorsApiMock) GetMonitors(ctx context.Context) (*resty.Response, MonitorsResponse, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*resty.Response), args.Get(1).(MonitorsResponse), args.Error(2)
+}
+
+func TestGetAllMonitors(t *testing.T) {
+	mockedApi := new(getMonitorsApiMock)
+	mockedApi.On("GetMonitors", mock.Anything).Return(apiResponseWithStatus(200), MonitorsResponse{
+		Monitors: []Monitor{
+			{Id: 9, Name: "CPU throttling", Status: "ENABLED", Tags: []string{"team:shop"}},
+		},
+	}, nil)
+
+	targets, err := getAllMonitors(context.Background(), mockedApi)
+	require.NoError(t, err)
+
+	require.Len(t, targets, 1)
+	assert.Equal(t, monitorTargetType, targets[0].TargetType)
+	assert.Equal(t, map[string][]string{
+		"stackstate.monitor.id":     {"9"},
+		"stackstate.monitor.name":   {"CPU throttling"},
+		"stackstate.monitor.status": {"ENABLED"},
+		"stackstate.monitor.tag":    {"team:shop"},
+	}, targets[0].Attributes)
+}
+
+func TestMonitorCheck(t *testing.T) {
+	t.Run("stays quiet success", func(t *testing.T) {
+		state := monitorCheckState(monitorCheckModeStaysQuiet)
+		mockedApi := new(getMonitorStatusApiMock)
+		mockedApi.On("GetMonitorStatus", mock.Anything, "9").Return(apiResponseWithStatus(200), monitorStatusWithCounts(4, 0, 0), nil)
+
+		status, err := CheckMonitorStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.False(t, status.Completed)
+		require.Nil(t, status.Error)
+		require.Equal(t, "success", (*status.Metrics)[0].Metric["state"])
+
+		state.End = time.Now().Add(-1 * time.Hour)
+		status, err = CheckMonitorStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.True(t, status.Completed)
+		require.Nil(t, status.Error)
+	})
+
+	t.Run("stays quiet failure", func(t *testing.T) {
+		state := monitorCheckState(monitorCheckModeStaysQuiet)
+		mockedApi := new(getMonitorStatusApiMock)
+		mockedApi.On("GetMonitorStatus", mock.Anything, "9").Return(apiResponseWithStatus(200), monitorStatusWithCounts(3, 0, 1), nil)
+
+		status, err := CheckMonitorStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.False(t, status.Completed)
+		require.NotNil(t, status.Error)
+		require.Equal(t, "danger", (*status.Metrics)[0].Metric["state"])
+	})
+
+	t.Run("fires success", func(t *testing.T) {
+		state := monitorCheckState(monitorCheckModeFires)
+		mockedApi := new(getMonitorStatusApiMock)
+		mockedApi.On("GetMonitorStatus", mock.Anything, "9").Return(apiResponseWithStatus(200), monitorStatusWithCounts(3, 1, 0), nil)
+
+		status, err := CheckMonitorStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.Nil(t, status.Error)
+		require.Equal(t, "warn", (*status.Metrics)[0].Metric["state"])
+
+		mockedApi.On("GetMonitorStatus", mock.Anything, "9").Unset()
+		mockedApi.On("GetMonitorStatus", mock.Anything, "9").Return(apiResponseWithStatus(200), monitorStatusWithCounts(4, 0, 0), nil)
+		state.End = time.Now().Add(-1 * time.Hour)
+		status, err = CheckMonitorStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.True(t, status.Completed)
+		require.Nil(t, status.Error)
+	})
+
+	t.Run("fires failure", func(t *testing.T) {
+		state := monitorCheckState(monitorCheckModeFires)
+		state.End = time.Now().Add(-1 * time.Hour)
+		mockedApi := new(getMonitorStatusApiMock)
+		mockedApi.On("GetMonitorStatus", mock.Anything, "9").Return(apiResponseWithStatus(200), monitorStatusWithCounts(4, 0, 0), nil)
+
+		status, err := CheckMonitorStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.True(t, status.Completed)
+		require.NotNil(t, status.Error)
+		require.Contains(t, status.Error.Title, "didn't fire")
+	})
+
+	t.Run("unexpected status code results in error", func(t *testing.T) {
+		state := monitorCheckState(monitorCheckModeStaysQuiet)
+		mockedApi := new(getMonitorStatusApiMock)
+		mockedApi.On("GetMonitorStatus", mock.Anything, "9").Return(apiResponseWithStatus(404), MonitorStatusResponse{}, nil)
+
+		status, err := CheckMonitorStatus(context.TODO(), &state, mockedApi)
+		require.Error(t, err)
+		require.Nil(t, status)
+	})
+}
+
+func monitorCheckState(mode string) MonitorStatusCheckState {
+	return MonitorStatusCheckState{
+		MonitorId:        "9",
+		MonitorName:      "CPU throttling",
+		End:              time.Now().Add(1 * time.Hour),
+		MonitorCheckMode: mode,
+	}
+}
+
+func monitorStatusWithCounts(clear, deviating, critical int) MonitorStatusResponse {
+	return MonitorStatusResponse{
+		MonitorHealthStateStateCounts: HealthStateCounts{
+			ClearCount:     clear,
+			DeviatingCount: deviating,
+			CriticalCount:  critical,
+		},
+	}
+}
+
+func TestMonitorCheckFiresWithin(t *testing.T) {
+	t.Run("fires within deadline", func(t *testing.T) {
+		state := monitorCheckState(monitorCheckModeFiresWithin)
+		state.Start = time.Now().Add(-3 * time.Second)
+		state.DetectionDeadline = time.Now().Add(1 * time.Minute)
+		mockedApi := new(getMonitorStatusApiMock)
+		mockedApi.On("GetMonitorStatus", mock.Anything, "9").Return(apiResponseWithStatus(200), monitorStatusWithCounts(3, 1, 0), nil)
+
+		status, err := CheckMonitorStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.Nil(t, status.Error)
+		require.GreaterOrEqual(t, state.TimeToDetect, 3*time.Second)
+		require.Len(t, *status.Metrics, 2)
+		require.NotNil(t, status.Summary)
+	})
+
+	t.Run("doesn't fire within deadline", func(t *testing.T) {
+		state := monitorCheckState(monitorCheckModeFiresWithin)
+		state.Start = time.Now().Add(-1 * time.Minute)
+		state.DetectionDeadline = time.Now().Add(-1 * time.Second)
+		mockedApi := new(getMonitorStatusApiMock)
+		mockedApi.On("GetMonitorStatus", mock.Anything, "9").Return(apiResponseWithStatus(200), monitorStatusWithCounts(4, 0, 0), nil)
+
+		status, err := CheckMonitorStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.False(t, status.Completed)
+		require.NotNil(t, status.Error)
+		require.Contains(t, status.Error.Title, "didn't fire within")
+	})
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_commons"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_sdk"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-stackstate/config"
+)
+
+type monitorDiscovery struct {
+	lastKnown lastKnownTargets
+}
+
+var (
+	_ discovery_kit_sdk.TargetDescriber    = (*monitorDiscovery)(nil)
+	_ discovery_kit_sdk.AttributeDescriber = (*monitorDiscovery)(nil)
+)
+
+type GetMonitorsApi interface {
+	GetMonitors(ctx context.Context) (*resty.Response, MonitorsResponse, error)
+}
+
+func NewMonitorDiscovery() discovery_kit_sdk.TargetDiscovery {
+	discovery := &monitorDiscovery{}
+	return discovery_kit_sdk.NewCachedTargetDiscovery(discovery,
+		discovery_kit_sdk.WithRefreshTargetsNow(),
+		discovery_kit_sdk.WithRefreshTargetsInterval(context.Background(), config.Config.DiscoveryInterval),
+	)
+}
+
+func (d *monitorDiscovery) Describe() discovery_kit_api.DiscoveryDescription {
+	return discovery_kit_api.DiscoveryDescription{
+		Id: monitorTargetType,
+		Discover: discovery_kit_api.DescribingEndpointReferenceWithCallInterval{
+			CallInterval: new(config.Config.DiscoveryInterval.String()),
+		},
+	}
+}
+
+func (d *monitorDiscovery) DescribeTarget() discovery_kit_api.TargetDescription {
+	return discovery_kit_api.TargetDescription{
+		Id:       monitorTargetType,
+		Label:    discovery_kit_api.PluralLabel{One: "StackState Monitor", Other: "StackState Monitors"},
+		Category: new("monitoring"),
+		Version:  extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:     new(serviceIcon),
+		Table: discovery_kit_api.Table{
+			Columns: []discovery_kit_api.Column{
+				{Attribute: attributeMonitorName},
+				{Attribute: attributeMonitorStatus},
+				{Attribute: attributeMonitorTag},
+			},
+			OrderBy: []discovery_kit_api.OrderBy{
+				{
+					Attribute: attributeMonitorName,
+					Direction: "ASC",
+				},
+			},
+		},
+	}
+}
+
+func (d *monitorDiscovery) DescribeAttributes() []discovery_kit_api.AttributeDescription {
+	return []discovery_kit_api.AttributeDescription{
+		{
+			Attribute: attributeMonitorName,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Monitor name",
+				Other: "Monitor names",
+			},
+		}, {
+			Attribute: attributeMonitorStatus,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Monitor status",
+				Other: "Monitor status",
+			},
+		}, {
+			Attribute: attributeMonitorTag,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Monitor tag",
+				Other: "Monitor tags",
+			},
+		},
+	}
+}
+
+func (d *monitorDiscovery) DiscoverTargets(ctx context.Context) ([]discovery_kit_api.Target, error) {
+	return d.lastKnown.keep(getAllMonitors(ctx, Client))
+}
+
+func getAllMonitors(ctx context.Context, api GetMonitorsApi) ([]discovery_kit_api.Target, error) {
+	result := make([]discovery_kit_api.Target, 0, 100)
+	res, stackStateResponse, err := api.GetMonitors(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve monitors from StackState: %w", err)
+	}
+
+	if res.StatusCode() != 200 {
+		return nil, fmt.Errorf("StackState API responded with unexpected status code %d while retrieving monitors", res.StatusCode())
+	}
+
+	for _, monitor := range stackStateResponse.Monitors {
+		result = append(result, toMonitor(monitor))
+	}
+	return discovery_kit_commons.ApplyAttributeExcludes(result, config.Config.DiscoveryAttributesExcludesMonitor), nil
+}
+
+func toMonitor(monitor Monitor) discovery_kit_api.Target {
+	attributes := map[string][]string{
+		attributeMonitorId:     {strconv.Itoa(monitor.Id)},
+		attributeMonitorName:   {monitor.Name},
+		attributeMonitorStatus: {monitor.Status},
+	}
+	if len(monitor.Tags) > 0 {
+		attributes[attributeMonitorTag] = monitor.Tags
+	}
+	return discovery_kit_api.Target{
+		Id:         strconv.Itoa(monitor.Id),
+		Label:      monitor.Name,
+		TargetType: monitorTargetType,
+		Attributes: attributes,
+	}
+}
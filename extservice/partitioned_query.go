@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/steadybit/extension-stackstate/config"
+)
+
+// incompleteRelationsWarning logs once that partitioned queries miss the relations between partitions.
+var incompleteRelationsWarning sync.Once
+
+// executePartitionedQuery splits the discovery query by the configured partitioning, e.g. into one query per cluster,
+// to stay below StackState's result size limits and request timeouts on huge topologies. The partitions are queried
+// with bounded concurrency and the components and relations are merged. Without partitioning the query is executed as
+// is.
+func (s *StackStateHttpClient) executePartitionedQuery(ctx context.Context, query string, relations snapshotRelations) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	if config.Config.DiscoveryPartitioning == "" {
+		return s.executeSnapshotQueryWithRelations(ctx, query, relations)
+	}
+	res, partitions, err := s.discoveryPartitions(ctx)
+	if err != nil || !res.IsSuccess() {
+		return res, ViewSnapshotResponseWrapper{}, err
+	}
+	if len(partitions) == 0 {
+		return s.executeSnapshotQueryWithRelations(ctx, query, relations)
+	}
+	if relations.indirect {
+		// StackState only returns the relations between the components of the same partition.
+		incompleteRelationsWarning.Do(func() {
+			log.Warn().Msgf("Discovery partitioning by %s is enabled, the service dependency attributes miss dependencies across %ss.",
+				config.Config.DiscoveryPartitioning, config.Config.DiscoveryPartitioning)
+		})
+	}
+
+	type partitionResult struct {
+		response *resty.Response
+		result   ViewSnapshotResponseWrapper
+		err      error
+	}
+	results := make([]partitionResult, len(partitions))
+	semaphore := make(chan struct{}, max(config.Config.DiscoveryConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, partition := range partitions {
+		wg.Go(func() {
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			response, result, err := s.executeSnapshotQueryWithRelations(ctx, fmt.Sprintf("%s AND (%s)", query, partition), relations)
+			results[i] = partitionResult{response: response, result: result, err: err}
+		})
+	}
+	wg.Wait()
+
+	var merged ViewSnapshotResponseWrapper
+	seen := make(map[int]bool)
+	seenRelations := make(map[Relation]bool)
+	for _, partition := range results {
+		if partition.err != nil || !partition.response.IsSuccess() {
+			// A partial target list would remove the targets of the failed partitions, so the whole query fails.
+			return partition.response, ViewSnapshotResponseWrapper{}, partition.err
+		}
+		for _, component := range partition.result.ViewSnapshotResponse.Components {
+			if !seen[component.Id] {
+				seen[component.Id] = true
+				merged.ViewSnapshotResponse.Components = append(merged.ViewSnapshotResponse.Components, component)
+			}
+		}
+		for _, relation := range partition.result.ViewSnapshotResponse.Relations {
+			if !seenRelations[relation] {
+				seenRelations[relation] = true
+				merged.ViewSnapshotResponse.Relations = append(merged.ViewSnapshotResponse.Relations, relation)
+			}
+		}
+	}
+	return results[0].response, merged, nil
+}
+
+// discoveryPartitions returns one STQL filter per cluster or namespace known to StackState and a last filter for the
+// components without the label of any of them, so they aren't lost. With namespace partitioning, that last filter only
+// looks at the namespace label, a component in a namespace of the same name as a known one in another cluster is missed.
+func (s *StackStateHttpClient) discoveryPartitions(ctx context.Context) (*resty.Response, []string, error) {
+	partitionType := "cluster"
+	if config.Config.DiscoveryPartitioning == config.DiscoveryPartitioningNamespace {
+		partitionType = "namespace"
+	}
+	res, stackStateResponse, err := s.executeSnapshotQuery(ctx, fmt.Sprintf("(type = %s)", stqlString(partitionType)))
+	if err != nil || !res.IsSuccess() {
+		return res, nil, err
+	}
+
+	if len(stackStateResponse.ViewSnapshotResponse.Components) == 0 {
+		return res, nil, nil
+	}
+	partitions := make([]string, 0, len(stackStateResponse.ViewSnapshotResponse.Components)+1)
+	var labels []string
+	for _, component := range stackStateResponse.ViewSnapshotResponse.Components {
+		if partitionType == "cluster" {
+			label := stqlString("cluster-name:" + component.Name)
+			partitions = append(partitions, fmt.Sprintf("label = %s", label))
+			labels = append(labels, label)
+		} else {
+			clusterName, _ := clusterAndNamespace(component)
+			label := stqlString("namespace:" + component.Name)
+			partitions = append(partitions, fmt.Sprintf("label = %s AND label = %s", stqlString("cluster-name:"+clusterName), label))
+			if !slices.Contains(labels, label) {
+				labels = append(labels, label)
+			}
+		}
+	}
+	partitions = append(partitions, fmt.Sprintf("NOT label IN (%s)", strings.Join(labels, ", ")))
+	return res, partitions, nil
+}
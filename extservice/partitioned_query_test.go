@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/steadybit/extension-stackstate/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshotServer(t *testing.T, responses map[string]string) (*StackStateHttpClient, *[]string) {
+	var mu sync.Mutex
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var request struct {
+			Query string `json:"query"`
+		}
+		require.NoError(t, json.Unmarshal(body, &request))
+		mu.Lock()
+		queries = append(queries, request.Query)
+		mu.Unlock()
+		response, ok := responses[request.Query]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+	return &StackStateHttpClient{Client: resty.New().SetBaseURL(srv.URL)}, &queries
+}
+
+func TestPartitionedQuery(t *testing.T) {
+	t.Cleanup(func() {
+		config.Config.DiscoveryPartitioning = ""
+	})
+	config.Config.DiscoveryConcurrency = 2
+
+	t.Run("queries per cluster and merges the results", func(t *testing.T) {
+		config.Config.DiscoveryPartitioning = config.DiscoveryPartitioningCluster
+		client, queries := snapshotServer(t, map[string]string{
+			`(type = "cluster")`: `{"viewSnapshotResponse":{"components":[{"id":1,"name":"prod"},{"id":2,"name":"dev"}]}}`,
+			`(type = "service") AND (label = "cluster-name:prod")`:                            `{"viewSnapshotResponse":{"components":[{"id":10,"name":"checkout"}]}}`,
+			`(type = "service") AND (label = "cluster-name:dev")`:                             `{"viewSnapshotResponse":{"components":[{"id":20,"name":"checkout"}]}}`,
+			`(type = "service") AND (NOT label IN ("cluster-name:prod", "cluster-name:dev"))`: `{"viewSnapshotResponse":{"components":[]}}`,
+		})
+
+		res, result, err := client.GetServiceSnapshots(context.Background())
+
+		require.NoError(t, err)
+		assert.True(t, res.IsSuccess())
+		assert.Len(t, *queries, 4)
+		ids := []int{}
+		for _, component := range result.ViewSnapshotResponse.Components {
+			ids = append(ids, component.Id)
+		}
+		assert.ElementsMatch(t, []int{10, 20}, ids)
+	})
+
+	t.Run("queries the components without a cluster label", func(t *testing.T) {
+		config.Config.DiscoveryPartitioning = config.DiscoveryPartitioningCluster
+		client, _ := snapshotServer(t, map[string]string{
+			`(type = "cluster")`: `{"viewSnapshotResponse":{"components":[{"id":1,"name":"prod"}]}}`,
+			`(type = "service") AND (label = "cluster-name:prod")`:        `{"viewSnapshotResponse":{"components":[{"id":10,"name":"checkout"}]}}`,
+			`(type = "service") AND (NOT label IN ("cluster-name:prod"))`: `{"viewSnapshotResponse":{"components":[{"id":30,"name":"legacy"}]}}`,
+		})
+
+		_, result, err := client.GetServiceSnapshots(context.Background())
+
+		require.NoError(t, err)
+		ids := []int{}
+		for _, component := range result.ViewSnapshotResponse.Components {
+			ids = append(ids, component.Id)
+		}
+		assert.ElementsMatch(t, []int{10, 30}, ids)
+	})
+
+	t.Run("queries per namespace", func(t *testing.T) {
+		config.Config.DiscoveryPartitioning = config.DiscoveryPartitioningNamespace
+		client, _ := snapshotServer(t, map[string]string{
+			`(type = "namespace")`: `{"viewSnapshotResponse":{"components":[{"id":1,"name":"shop","properties":{"clusterNameIdentifier":"urn:cluster:/kubernetes:prod"}}]}}`,
+			`(type = "deployment") AND (label = "cluster-name:prod" AND label = "namespace:shop")`: `{"viewSnapshotResponse":{"components":[{"id":10,"name":"checkout"}]}}`,
+			`(type = "deployment") AND (NOT label IN ("namespace:shop"))`:                          `{"viewSnapshotResponse":{"components":[{"id":20,"name":"unlabelled"}]}}`,
+		})
+
+		_, result, err := client.GetComponentSnapshots(context.Background(), "deployment")
+
+		require.NoError(t, err)
+		assert.Len(t, result.ViewSnapshotResponse.Components, 2)
+	})
+
+	t.Run("merges the relations and warns about the missing relations across partitions", func(t *testing.T) {
+		config.Config.DiscoveryPartitioning = config.DiscoveryPartitioningNamespace
+		config.Config.DiscoveryServiceRelations = true
+		t.Cleanup(func() {
+			config.Config.DiscoveryServiceRelations = false
+		})
+		var logs bytes.Buffer
+		logger := log.Logger
+		log.Logger = zerolog.New(&logs)
+		t.Cleanup(func() {
+			log.Logger = logger
+		})
+		incompleteRelationsWarning = sync.Once{}
+		client, _ := snapshotServer(t, map[string]string{
+			`(type = "namespace")`: `{"viewSnapshotResponse":{"components":[
+				{"id":1,"name":"shop","properties":{"clusterNameIdentifier":"urn:cluster:/kubernetes:prod"}},
+				{"id":2,"name":"billing","properties":{"clusterNameIdentifier":"urn:cluster:/kubernetes:prod"}}
+			]}}`,
+			// The relation from checkout to invoices crosses the namespaces and is returned by neither partition.
+			`(type = "service") AND (label = "cluster-name:prod" AND label = "namespace:shop")`: `{"viewSnapshotResponse":{
+				"components":[{"id":10,"name":"checkout"},{"id":11,"name":"postgres"}],
+				"relations":[{"source":10,"target":11,"dependencyDirection":"ONE_WAY"}]
+			}}`,
+			`(type = "service") AND (label = "cluster-name:prod" AND label = "namespace:billing")`: `{"viewSnapshotResponse":{
+				"components":[{"id":20,"name":"invoices"},{"id":21,"name":"postgres"}],
+				"relations":[{"source":20,"target":21,"dependencyDirection":"ONE_WAY"}]
+			}}`,
+			`(type = "service") AND (NOT label IN ("namespace:shop", "namespace:billing"))`: `{"viewSnapshotResponse":{"components":[]}}`,
+		})
+
+		_, result, err := client.GetServiceSnapshots(context.Background())
+		_, _, _ = client.GetServiceSnapshots(context.Background())
+
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []Relation{
+			{Source: 10, Target: 11, DependencyDirection: "ONE_WAY"},
+			{Source: 20, Target: 21, DependencyDirection: "ONE_WAY"},
+		}, result.ViewSnapshotResponse.Relations)
+		assert.Equal(t, 1, strings.Count(logs.String(), "miss dependencies across namespaces"))
+	})
+
+	t.Run("fails if one partition fails", func(t *testing.T) {
+		config.Config.DiscoveryPartitioning = config.DiscoveryPartitioningCluster
+		client, _ := snapshotServer(t, map[string]string{
+			`(type = "cluster")`: `{"viewSnapshotResponse":{"components":[{"id":1,"name":"prod"},{"id":2,"name":"dev"}]}}`,
+			`(type = "service") AND (label = "cluster-name:prod")`: `{"viewSnapshotResponse":{"components":[{"id":10,"name":"checkout"}]}}`,
+		})
+
+		res, result, err := client.GetServiceSnapshots(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode())
+		assert.Empty(t, result.ViewSnapshotResponse.Components)
+	})
+
+	t.Run("falls back to a single query without partitions", func(t *testing.T) {
+		config.Config.DiscoveryPartitioning = config.DiscoveryPartitioningCluster
+		client, _ := snapshotServer(t, map[string]string{
+			`(type = "cluster")`: `{"viewSnapshotResponse":{"components":[]}}`,
+			`(type = "service")`: `{"viewSnapshotResponse":{"components":[{"id":10,"name":"checkout"}]}}`,
+		})
+
+		_, result, err := client.GetServiceSnapshots(context.Background())
+
+		require.NoError(t, err)
+		assert.Len(t, result.ViewSnapshotResponse.Components, 1)
+	})
+}
+
+func TestDiscoveryFilter(t *testing.T) {
+	t.Cleanup(func() {
+		config.Config.DiscoveryFilter = ""
+		config.Config.DiscoveryPartitioning = ""
+	})
+	config.Config.DiscoveryFilter = `label in ("env:prod") OR name = "x"`
+	config.Config.DiscoveryPartitioning = config.DiscoveryPartitioningCluster
+	client, queries := snapshotServer(t, map[string]string{
+		`(type = "cluster")`: `{"viewSnapshotResponse":{"components":[{"id":1,"name":"prod"}]}}`,
+		`(type = "service") AND (label in ("env:prod") OR name = "x") AND (label = "cluster-name:prod")`:        `{"viewSnapshotResponse":{"components":[{"id":10,"name":"checkout"}]}}`,
+		`(type = "service") AND (label in ("env:prod") OR name = "x") AND (NOT label IN ("cluster-name:prod"))`: `{"viewSnapshotResponse":{"components":[]}}`,
+	})
+
+	_, result, err := client.GetServiceSnapshots(context.Background())
+
+	require.NoError(t, err)
+	assert.Len(t, result.ViewSnapshotResponse.Components, 1)
+	assert.Len(t, *queries, 3)
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/steadybit/extension-stackstate/config"
+)
+
+// errCircuitOpen is returned without calling StackState while the circuit breaker is open.
+var errCircuitOpen = errors.New("StackState API circuit breaker is open after repeated failures")
+
+// ConfigureResilience adds the configured retries and circuit breaker to the StackState API client.
+func ConfigureResilience(client *resty.Client) {
+	client.SetRetryCount(config.Config.ApiRetryCount)
+	client.SetRetryWaitTime(config.Config.ApiRetryWaitTime)
+	client.SetRetryMaxWaitTime(config.Config.ApiRetryMaxWaitTime)
+	client.SetRetryAfter(retryAfter)
+	client.AddRetryCondition(isRetryable)
+	client.AddRetryHook(releaseRetriedBody)
+	if config.Config.ApiCircuitBreakerThreshold > 0 {
+		breaker := &circuitBreaker{
+			threshold:    config.Config.ApiCircuitBreakerThreshold,
+			openDuration: config.Config.ApiCircuitBreakerOpenDuration,
+		}
+		client.OnBeforeRequest(breaker.beforeRequest)
+		client.OnSuccess(breaker.onSuccess)
+		client.OnError(breaker.onError)
+	}
+}
+
+// isRetryable retries network errors, rate limiting and server errors, but not requests rejected by an open circuit
+// breaker.
+func isRetryable(response *resty.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, errCircuitOpen)
+	}
+	return response.StatusCode() == http.StatusTooManyRequests || response.StatusCode() >= http.StatusInternalServerError
+}
+
+// releaseRetriedBody closes the body of responses that are not parsed by resty, e.g. the streamed snapshot responses,
+// before the request is retried. Otherwise, the connection leaks.
+func releaseRetriedBody(response *resty.Response, _ error) {
+	if response == nil || response.RawResponse == nil || response.RawResponse.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.RawResponse.Body, maxErrorBodySize))
+	_ = response.RawResponse.Body.Close()
+}
+
+// retryAfter honors the Retry-After header, returning 0 makes resty fall back to the jittered exponential backoff.
+func retryAfter(_ *resty.Client, response *resty.Response) (time.Duration, error) {
+	if response == nil {
+		return 0, nil
+	}
+	header := response.Header().Get("Retry-After")
+	if header == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date), nil
+	}
+	return 0, nil
+}
+
+// circuitBreaker stops calling StackState after threshold consecutive failed API calls. An API call counts once,
+// after all of its retries. Once openDuration passed, a single trial call is let through; its success closes the
+// circuit again, its failure keeps it open for another period.
+type circuitBreaker struct {
+	threshold    int
+	openDuration time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// beforeRequest rejects an API call while the circuit is open. Retries of an admitted call are not checked again.
+func (c *circuitBreaker) beforeRequest(_ *resty.Client, request *resty.Request) error {
+	if request.Attempt > 1 || c.allow() {
+		return nil
+	}
+	return errCircuitOpen
+}
+
+// onSuccess records the final response of an API call, a 429 or 5xx left after the retries is a failure.
+func (c *circuitBreaker) onSuccess(_ *resty.Client, response *resty.Response) {
+	c.record(response.StatusCode() != http.StatusTooManyRequests && response.StatusCode() < http.StatusInternalServerError)
+}
+
+// onError records an API call failing after the retries, except those rejected by the open circuit and those given up
+// by the caller, e.g. a status check stopped mid-request. They tell nothing about StackState's health.
+func (c *circuitBreaker) onError(request *resty.Request, err error) {
+	if errors.Is(err, errCircuitOpen) {
+		return
+	}
+	if request.Context().Err() != nil {
+		c.abandon()
+		return
+	}
+	c.record(false)
+}
+
+func (c *circuitBreaker) allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures < c.threshold {
+		return true
+	}
+	if c.trial || time.Now().Before(c.openUntil) {
+		return false
+	}
+	c.trial = true
+	return true
+}
+
+// abandon lets another trial call through if the abandoned call was the trial, without counting it either way.
+func (c *circuitBreaker) abandon() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trial = false
+}
+
+func (c *circuitBreaker) record(success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trial = false
+	if success {
+		if c.failures >= c.threshold {
+			log.Info().Msg("StackState API is reachable again, closing the circuit breaker.")
+		}
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= c.threshold {
+		if c.failures == c.threshold {
+			log.Warn().Msgf("StackState API failed %d times in a row, pausing calls for %s.", c.failures, c.openDuration)
+		}
+		c.openUntil = time.Now().Add(c.openDuration)
+	}
+}
@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/extension-stackstate/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resilientClient(t *testing.T, handler http.HandlerFunc) *StackStateHttpClient {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	retryCount, retryWaitTime, retryMaxWaitTime := config.Config.ApiRetryCount, config.Config.ApiRetryWaitTime, config.Config.ApiRetryMaxWaitTime
+	breakerThreshold, breakerOpenDuration := config.Config.ApiCircuitBreakerThreshold, config.Config.ApiCircuitBreakerOpenDuration
+	t.Cleanup(func() {
+		config.Config.ApiRetryCount, config.Config.ApiRetryWaitTime, config.Config.ApiRetryMaxWaitTime = retryCount, retryWaitTime, retryMaxWaitTime
+		config.Config.ApiCircuitBreakerThreshold, config.Config.ApiCircuitBreakerOpenDuration = breakerThreshold, breakerOpenDuration
+	})
+	config.Config.ApiRetryCount = 2
+	config.Config.ApiRetryWaitTime = time.Millisecond
+	config.Config.ApiRetryMaxWaitTime = 10 * time.Millisecond
+	config.Config.ApiCircuitBreakerThreshold = 3
+	config.Config.ApiCircuitBreakerOpenDuration = time.Hour
+	client := resty.New().SetBaseURL(srv.URL)
+	ConfigureResilience(client)
+	return &StackStateHttpClient{Client: client}
+}
+
+func TestResilience(t *testing.T) {
+	t.Run("retries server errors", func(t *testing.T) {
+		var calls atomic.Int32
+		client := resilientClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"monitors":[{"id":1}]}`))
+		})
+
+		res, monitors, err := client.GetMonitors(context.Background())
+
+		require.NoError(t, err)
+		assert.True(t, res.IsSuccess())
+		assert.Len(t, monitors.Monitors, 1)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var calls atomic.Int32
+		client := resilientClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		res, _, err := client.GetMonitors(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode())
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("opens the circuit after repeated failures", func(t *testing.T) {
+		var calls atomic.Int32
+		client := resilientClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		for i := 1; i <= 3; i++ {
+			_, _, err := client.GetMonitors(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int32(3*i), calls.Load(), "every failed API call is retried twice, but counts once")
+		}
+
+		_, _, err := client.GetMonitors(context.Background())
+		require.ErrorIs(t, err, errCircuitOpen)
+		assert.Equal(t, int32(9), calls.Load())
+	})
+
+	t.Run("a call succeeding on retry resets the failures", func(t *testing.T) {
+		var calls atomic.Int32
+		client := resilientClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1)%3 != 0 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"monitors":[]}`))
+		})
+
+		for range 4 {
+			res, _, err := client.GetMonitors(context.Background())
+			require.NoError(t, err)
+			assert.True(t, res.IsSuccess())
+		}
+		assert.Equal(t, int32(12), calls.Load())
+	})
+
+	t.Run("calls given up by the caller are no failures", func(t *testing.T) {
+		var calls atomic.Int32
+		client := resilientClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= 3 {
+				<-r.Context().Done()
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"monitors":[]}`))
+		})
+
+		for range 2 {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			_, _, err := client.GetMonitors(ctx)
+			cancel()
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		_, _, err := client.GetMonitors(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+
+		res, _, err := client.GetMonitors(context.Background())
+		require.NoError(t, err)
+		assert.True(t, res.IsSuccess())
+		assert.Equal(t, int32(4), calls.Load())
+	})
+}
+
+func TestRetryAfter(t *testing.T) {
+	response := func(header string) *resty.Response {
+		res := &http.Response{Header: http.Header{}}
+		if header != "" {
+			res.Header.Set("Retry-After", header)
+		}
+		return &resty.Response{RawResponse: res}
+	}
+
+	wait, err := retryAfter(nil, response("7"))
+	require.NoError(t, err)
+	assert.Equal(t, 7*time.Second, wait)
+
+	wait, err = retryAfter(nil, response(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, wait, float64(2*time.Second))
+
+	wait, err = retryAfter(nil, response(""))
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestCircuitBreakerTrialRequest(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 1, openDuration: time.Hour}
+	breaker.record(false)
+	assert.False(t, breaker.allow())
+
+	breaker.openUntil = time.Now().Add(-time.Second)
+	assert.True(t, breaker.allow())
+	assert.False(t, breaker.allow(), "only one trial request while half-open")
+
+	breaker.record(true)
+	assert.True(t, breaker.allow())
+	assert.True(t, breaker.allow())
+}
+
+func TestCircuitBreakerAbandonedTrialRequest(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 1, openDuration: time.Hour}
+	breaker.record(false)
+	breaker.openUntil = time.Now().Add(-time.Second)
+	assert.True(t, breaker.allow())
+
+	breaker.abandon()
+	assert.True(t, breaker.allow(), "an abandoned trial request lets the next one through")
+	assert.False(t, breaker.allow())
+}
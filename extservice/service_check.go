@@ -11,7 +11,9 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -24,7 +26,9 @@ import (
 	"github.com/steadybit/extension-stackstate/config"
 )
 
-type ServiceStatusCheckAction struct{}
+type ServiceStatusCheckAction struct {
+	kind componentKind
+}
 
 // Make sure action implements all required interfaces
 var (
@@ -33,25 +37,87 @@ var (
 )
 
 type ServiceStatusCheckState struct {
-	ServiceId          string
-	ServiceName        string
-	ClusterName        string
-	End                time.Time
-	ExpectedStatus     string
+	// TargetType identifies the checked component kind, the ServiceId and ServiceName fields hold the id and name of
+	// a component of that kind.
+	TargetType  string
+	ServiceId   string
+	ServiceName string
+	ClusterName string
+	// MissingComponent is the configured behavior for a missing component, empty for experiments created before the
+	// parameter existed.
+	MissingComponent string
+	// Urn and Namespace are used to find the component again if its numeric id disappears.
+	Urn       string
+	Namespace string
+	// OwnerPrefix is the name prefix of the components of the same owner, see componentKind.ownerPrefix. Siblings
+	// holds the ids of the other components of the owner at the start, so they aren't mistaken for the replacement.
+	OwnerPrefix string
+	Siblings    []string
+	End         time.Time
+	// ExpectedStatus holds the accepted health states.
+	ExpectedStatus     []string
 	StatusCheckMode    string
 	StatusCheckSuccess bool
 	FailEarly          bool
+	// ErrorKind identifies the check behind the reported error, so the health history only overrides its own verdict.
+	ErrorKind string
 	// DeviationTitle remembers the first observed deviation in 'All the time' + fail-at-end mode
 	// (FailEarly = false) so it can be reported once the step ends.
 	DeviationTitle string
+	Start          time.Time
+	// DetectionDeadline, Detected and TimeToDetect track the 'Leaves CLEAR within deadline' mode.
+	DetectionDeadline time.Time
+	Detected          bool
+	TimeToDetect      time.Duration
+	// GracePeriod is the minimum continuous time a deviation must last in 'All the time' mode before it counts.
+	GracePeriod time.Duration
+	// RecoveryWindow and TimeToRecover track the 'Recovers within window' mode. DeviationStart is shared with the
+	// 'All the time' grace period and is zero while the component has the expected status.
+	RecoveryWindow time.Duration
+	DeviationStart time.Time
+	TimeToRecover  time.Duration
+	// MinimumPercentage, MatchingPolls and TotalPolls track the 'Percentage of the time' mode.
+	MinimumPercentage float64
+	MatchingPolls     int
+	TotalPolls        int
+	// MaxTransitions, LastHealthState and Transitions track the flap detection, which is disabled if MaxTransitions
+	// is 0. It applies on top of every status check mode.
+	MaxTransitions  int
+	LastHealthState string
+	Transitions     []HealthStateTransition
+}
+
+// HealthStateTransition is a health state change observed by the flap detection, Offset is relative to the step start.
+type HealthStateTransition struct {
+	Offset time.Duration
+	From   string
+	To     string
 }
 
 type GetSnapshotApi interface {
 	GetServiceSnapshot(ctx context.Context, serviceId string) (*resty.Response, ViewSnapshotResponseWrapper, error)
+	QuerySnapshots(ctx context.Context, query string) (*resty.Response, ViewSnapshotResponseWrapper, error)
 }
 
 func NewServiceStatusCheckAction() action_kit_sdk.Action[ServiceStatusCheckState] {
-	return &ServiceStatusCheckAction{}
+	return &ServiceStatusCheckAction{kind: serviceKind}
+}
+
+func NewWorkloadStatusCheckActions() []action_kit_sdk.Action[ServiceStatusCheckState] {
+	actions := make([]action_kit_sdk.Action[ServiceStatusCheckState], 0, len(workloadKinds))
+	for _, kind := range workloadKinds {
+		actions = append(actions, &ServiceStatusCheckAction{kind: kind})
+	}
+	return actions
+}
+
+func NewCustomStatusCheckActions() []action_kit_sdk.Action[ServiceStatusCheckState] {
+	kinds := customKinds()
+	actions := make([]action_kit_sdk.Action[ServiceStatusCheckState], 0, len(kinds))
+	for _, kind := range kinds {
+		actions = append(actions, &ServiceStatusCheckAction{kind: kind})
+	}
+	return actions
 }
 
 func (m *ServiceStatusCheckAction) NewEmptyState() ServiceStatusCheckState {
@@ -60,21 +126,15 @@ func (m *ServiceStatusCheckAction) NewEmptyState() ServiceStatusCheckState {
 
 func (m *ServiceStatusCheckAction) Describe() action_kit_api.ActionDescription {
 	return action_kit_api.ActionDescription{
-		Id:          fmt.Sprintf("%s.check", serviceTargetType),
-		Label:       "StackState Service",
-		Description: "collects information about the service status and optionally verifies that the status has an expected status.",
+		Id:          fmt.Sprintf("%s.check", m.kind.targetType),
+		Label:       fmt.Sprintf("StackState %s", m.kind.label.One),
+		Description: fmt.Sprintf("collects information about the %s status and optionally verifies that the status has an expected status.", strings.ToLower(m.kind.label.One)),
 		Version:     extbuild.GetSemverVersionStringOrUnknown(),
 		Icon:        new(serviceIcon),
 		TargetSelection: new(action_kit_api.TargetSelection{
-			TargetType:          serviceTargetType,
+			TargetType:          m.kind.targetType,
 			QuantityRestriction: extutil.Ptr(action_kit_api.QuantityRestrictionAll),
-			SelectionTemplates: new([]action_kit_api.TargetSelectionTemplate{
-				{
-					Label:       "service name",
-					Description: new("Find service by cluster, namespace and service"),
-					Query:       "k8s.cluster-name=\"\" AND k8s.namespace=\"\" AND k8s.service.name=\"\"",
-				},
-			}),
+			SelectionTemplates:  new(m.kind.selectionTemplates()),
 		}),
 		Technology: new("StackState"),
 
@@ -93,8 +153,8 @@ func (m *ServiceStatusCheckAction) Describe() action_kit_api.ActionDescription {
 			{
 				Name:        "expectedStatus",
 				Label:       "Expected Status",
-				Description: new(""),
-				Type:        action_kit_api.ActionParameterTypeString,
+				Description: new("The accepted health states, e.g. CLEAR and DEVIATING to only fail on CRITICAL."),
+				Type:        action_kit_api.ActionParameterTypeStringArray,
 				Options: new([]action_kit_api.ParameterOption{
 					action_kit_api.ExplicitParameterOption{
 						Label: "CLEAR",
@@ -131,10 +191,23 @@ func (m *ServiceStatusCheckAction) Describe() action_kit_api.ActionDescription {
 						Label: "At least once",
 						Value: statusCheckModeAtLeastOnce,
 					},
+					action_kit_api.ExplicitParameterOption{
+						Label: "Leaves CLEAR within deadline",
+						Value: statusCheckModeDetectedWithin,
+					},
+					action_kit_api.ExplicitParameterOption{
+						Label: "Recovers within window",
+						Value: statusCheckModeRecoversWithin,
+					},
+					action_kit_api.ExplicitParameterOption{
+						Label: "Percentage of the time",
+						Value: statusCheckModePercentageOfTime,
+					},
 				}),
 				Required: new(true),
 				Order:    new(4),
 			},
+			detectionDeadlineParameter,
 			{
 				Name:         "failEarly",
 				Label:        "Fail early",
@@ -145,16 +218,84 @@ func (m *ServiceStatusCheckAction) Describe() action_kit_api.ActionDescription {
 				Required:     new(false),
 				Order:        new(5),
 			},
+			{
+				Name:         "recoveryWindow",
+				Label:        "Recovery window",
+				Description:  new("Only used by the 'Recovers within window' mode: how long a deviation from the expected status is tolerated before the check fails."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				DefaultValue: new("60s"),
+				Required:     new(false),
+				Order:        new(6),
+			},
+			{
+				Name:         "minimumPercentage",
+				Label:        "Minimum percentage",
+				Description:  new("Only used by the 'Percentage of the time' mode: the minimum percentage of status polls that must report the expected status."),
+				Type:         action_kit_api.ActionParameterTypePercentage,
+				DefaultValue: new("95"),
+				MinValue:     new(0),
+				MaxValue:     new(100),
+				Required:     new(false),
+				Order:        new(7),
+			},
+			{
+				Name:         "gracePeriod",
+				Label:        "Grace period",
+				Description:  new("Only used by the 'All the time' mode: how long a deviation from the expected status must last continuously before it counts. Use it to tolerate short health flickers, e.g. during rollouts."),
+				Type:         action_kit_api.ActionParameterTypeDuration,
+				DefaultValue: new("0s"),
+				Advanced:     new(true),
+				Required:     new(false),
+				Order:        new(8),
+			},
+			{
+				Name:         "maxTransitions",
+				Label:        "Maximum health state changes",
+				Description:  new("Fails the check if the health state changes more often than this during the step, e.g. a service toggling between CLEAR and DEVIATING. 0 disables the flap detection."),
+				Type:         action_kit_api.ActionParameterTypeInteger,
+				DefaultValue: new("0"),
+				MinValue:     new(0),
+				Advanced:     new(true),
+				Required:     new(false),
+				Order:        new(9),
+			},
+			{
+				Name:        "missingComponent",
+				Label:       "When the component is missing",
+				Description: new("What it means when StackState doesn't return the component anymore, e.g. because it was deleted on purpose. Also applies to unexpected StackState API responses. If not set, a missing component errors the step and unexpected responses count as UNKNOWN."),
+				Type:        action_kit_api.ActionParameterTypeString,
+				Options: new([]action_kit_api.ParameterOption{
+					action_kit_api.ExplicitParameterOption{
+						Label: "Error the step",
+						Value: missingComponentError,
+					},
+					action_kit_api.ExplicitParameterOption{
+						Label: "Fail the check",
+						Value: missingComponentFail,
+					},
+					action_kit_api.ExplicitParameterOption{
+						Label: "Treat as UNKNOWN",
+						Value: missingComponentUnknown,
+					},
+					action_kit_api.ExplicitParameterOption{
+						Label: "Ignore",
+						Value: missingComponentIgnore,
+					},
+				}),
+				Advanced: new(true),
+				Required: new(false),
+				Order:    new(10),
+			},
 		},
 		Widgets: new([]action_kit_api.Widget{
 			action_kit_api.StateOverTimeWidget{
 				Type:  action_kit_api.ComSteadybitWidgetStateOverTime,
-				Title: "StackState Service Status",
+				Title: fmt.Sprintf("StackState %s Status", m.kind.label.One),
 				Identity: action_kit_api.StateOverTimeWidgetIdentityConfig{
-					From: attributeServiceId,
+					From: m.kind.idAttribute,
 				},
 				Label: action_kit_api.StateOverTimeWidgetLabelConfig{
-					From: attributeK8ServiceName,
+					From: m.kind.nameAttribute,
 				},
 				State: action_kit_api.StateOverTimeWidgetStateConfig{
 					From: attributeState,
@@ -177,27 +318,71 @@ func (m *ServiceStatusCheckAction) Describe() action_kit_api.ActionDescription {
 }
 
 func (m *ServiceStatusCheckAction) Prepare(_ context.Context, state *ServiceStatusCheckState, request action_kit_api.PrepareActionRequestBody) (*action_kit_api.PrepareResult, error) {
-	serviceId := request.Target.Attributes[attributeServiceId]
+	serviceId := request.Target.Attributes[m.kind.idAttribute]
 	if len(serviceId) == 0 {
-		return nil, new(extension_kit.ToError("Target is missing the 'stackstate.service.id' attribute.", nil))
+		return nil, new(extension_kit.ToError(fmt.Sprintf("Target is missing the '%s' attribute.", m.kind.idAttribute), nil))
 	}
 
 	duration := request.Config["duration"].(float64)
-	end := time.Now().Add(time.Millisecond * time.Duration(duration))
+	start := time.Now()
+	end := start.Add(time.Millisecond * time.Duration(duration))
 
-	var expectedStatus string
-	if request.Config["expectedStatus"] != nil {
-		expectedStatus = fmt.Sprintf("%v", request.Config["expectedStatus"])
+	var expectedStatus []string
+	switch configured := request.Config["expectedStatus"].(type) {
+	case nil:
+	case []any:
+		for _, status := range configured {
+			if status := fmt.Sprintf("%v", status); status != "" {
+				expectedStatus = append(expectedStatus, status)
+			}
+		}
+	default:
+		// Experiments created before the parameter became a multi-select pass a single status, which is empty if
+		// none was selected.
+		if status := fmt.Sprintf("%v", configured); status != "" {
+			expectedStatus = []string{status}
+		}
 	}
 	var statusCheckMode = statusCheckModeAllTheTime
 	if request.Config["statusCheckMode"] != nil {
 		statusCheckMode = fmt.Sprintf("%v", request.Config["statusCheckMode"])
 	}
 
+	state.TargetType = m.kind.targetType
 	state.ServiceId = serviceId[0]
-	state.ServiceName = request.Target.Attributes[attributeK8ServiceName][0]
-	state.ClusterName = request.Target.Attributes[attributeK8ClusterName][0]
+	state.ServiceName = request.Target.Attributes[m.kind.nameAttribute][0]
+	if clusterName := request.Target.Attributes[attributeK8ClusterName]; len(clusterName) > 0 {
+		state.ClusterName = clusterName[0]
+	}
+	if namespace := request.Target.Attributes[attributeK8Namespace]; len(namespace) > 0 {
+		state.Namespace = namespace[0]
+	}
+	if urn := request.Target.Attributes[m.kind.urnAttribute]; len(urn) > 0 {
+		state.Urn = urn[0]
+	}
+	if m.kind.ownerPrefix != nil {
+		state.OwnerPrefix = m.kind.ownerPrefix(state.ServiceName)
+	}
+	state.Start = start
 	state.End = end
+	state.DetectionDeadline = detectionDeadline(request.Config, start, end)
+	state.RecoveryWindow = 60 * time.Second
+	if recoveryWindow, ok := request.Config["recoveryWindow"].(float64); ok {
+		state.RecoveryWindow = time.Millisecond * time.Duration(recoveryWindow)
+	}
+	if gracePeriod, ok := request.Config["gracePeriod"].(float64); ok {
+		state.GracePeriod = time.Millisecond * time.Duration(gracePeriod)
+	}
+	if missingComponent, ok := request.Config["missingComponent"].(string); ok {
+		state.MissingComponent = missingComponent
+	}
+	if maxTransitions, ok := request.Config["maxTransitions"].(float64); ok {
+		state.MaxTransitions = int(maxTransitions)
+	}
+	state.MinimumPercentage = 95
+	if minimumPercentage, ok := request.Config["minimumPercentage"].(float64); ok {
+		state.MinimumPercentage = minimumPercentage
+	}
 	state.ExpectedStatus = expectedStatus
 	state.StatusCheckMode = statusCheckMode
 	state.StatusCheckSuccess = state.StatusCheckMode == statusCheckModeAllTheTime
@@ -210,44 +395,108 @@ func (m *ServiceStatusCheckAction) Prepare(_ context.Context, state *ServiceStat
 	return nil, nil
 }
 
-func (m *ServiceStatusCheckAction) Start(_ context.Context, _ *ServiceStatusCheckState) (*action_kit_api.StartResult, error) {
+func (m *ServiceStatusCheckAction) Start(ctx context.Context, state *ServiceStatusCheckState) (*action_kit_api.StartResult, error) {
+	StartStatusCheck(ctx, state, sharedSnapshotPoller())
 	return nil, nil
 }
 
+// StartStatusCheck remembers the siblings of a component with an owner, so its replacement can be told apart from
+// them once the component is gone. Without them, only an owner with a single component can be followed.
+func StartStatusCheck(ctx context.Context, state *ServiceStatusCheckState, api GetSnapshotApi) {
+	query := ownerQuery(state)
+	if query == "" {
+		return
+	}
+	res, stackStateResponse, err := api.QuerySnapshots(ctx, query)
+	if err != nil || !res.IsSuccess() {
+		log.Warn().Err(err).Msgf("Failed to retrieve the siblings of component %s with query %s.", state.ServiceId, query)
+		return
+	}
+	for _, component := range stackStateResponse.ViewSnapshotResponse.Components {
+		if id := strconv.Itoa(component.Id); id != state.ServiceId {
+			state.Siblings = append(state.Siblings, id)
+		}
+	}
+}
+
 func (m *ServiceStatusCheckAction) Status(ctx context.Context, state *ServiceStatusCheckState) (*action_kit_api.StatusResult, error) {
-	return MonitorStatusCheckStatus(ctx, state, Client)
+	result, err := MonitorStatusCheckStatus(ctx, state, sharedSnapshotPoller())
+	if err != nil {
+		return nil, err
+	}
+	return evaluateHealthHistory(ctx, state, result, Client), nil
 }
 
 func MonitorStatusCheckStatus(ctx context.Context, state *ServiceStatusCheckState, api GetSnapshotApi) (*action_kit_api.StatusResult, error) {
 	now := time.Now()
-	component, err := loadServiceComponent(ctx, state, api)
+	kind, ok := componentKindOf(state.TargetType)
+	if !ok {
+		return nil, unknownTargetTypeError(state.TargetType)
+	}
+	component, missing, err := loadServiceComponent(ctx, state, api)
 	if err != nil {
 		return nil, err
 	}
 	completed := now.After(state.End)
+	// A missing component is not recorded by the modes below, but the step still gets their verdicts once it ends.
+	var missingError *action_kit_api.ActionKitError
+	if missing != nil {
+		if missing.behavior == missingComponentFail {
+			completed = true
+			missingError = new(action_kit_api.ActionKitError{
+				Title:  missing.reason,
+				Status: extutil.Ptr(action_kit_api.Failed),
+			})
+		} else if !completed {
+			// missingComponentIgnore: the poll neither counts as success nor as failure.
+			return &action_kit_api.StatusResult{Completed: false}, nil
+		}
+	}
 
+	var metrics []action_kit_api.Metric
+	if component != nil {
+		metrics = append(metrics, *toMetric(component, kind, now))
+	}
+	name := observedName(state, component)
 	var checkError *action_kit_api.ActionKitError
-	if len(state.ExpectedStatus) > 0 {
-		componentHealthState := component.State.HealthState
+	var errorKind string
+	var summary *action_kit_api.Summary
+	var modeMetrics []action_kit_api.Metric
+	if state.StatusCheckMode == statusCheckModeDetectedWithin {
+		checkError, summary, modeMetrics = evaluateDetectedWithin(state, kind, component, now, completed)
+	} else if state.StatusCheckMode == statusCheckModeRecoversWithin {
+		checkError, summary, modeMetrics = evaluateRecoversWithin(state, kind, component, now, completed)
+	} else if state.StatusCheckMode == statusCheckModePercentageOfTime {
+		checkError, summary, modeMetrics = evaluatePercentageOfTime(state, kind, component, now, completed)
+	} else if len(state.ExpectedStatus) > 0 {
 		if state.StatusCheckMode == statusCheckModeAllTheTime {
-			if componentHealthState != state.ExpectedStatus {
+			if component == nil {
+				// Nothing observed, a pending deviation is still reported below.
+			} else if state.isExpectedStatus(component.State.HealthState) {
+				state.DeviationStart = time.Time{}
+			} else if state.DeviationStart.IsZero() {
+				state.DeviationStart = now
+			}
+			if component != nil && !state.DeviationStart.IsZero() && now.Sub(state.DeviationStart) >= state.GracePeriod {
 				if state.FailEarly {
 					// Fail as soon as a deviating status is observed (present tense - it is deviating now).
 					checkError = new(action_kit_api.ActionKitError{
-						Title: fmt.Sprintf("Service '%s' (id %s) has status '%s' whereas '%s' is expected.",
+						Title: fmt.Sprintf("%s '%s' (id %s) has status '%s' whereas %s is expected.",
+							kind.label.One,
 							component.Name,
 							state.ServiceId,
-							componentHealthState,
-							state.ExpectedStatus),
+							component.State.HealthState,
+							state.expectedStatusText()),
 						Status: extutil.Ptr(action_kit_api.Failed),
 					})
 				} else if state.DeviationTitle == "" {
 					// Remember the first deviation to report at the end (past tense - it may have recovered).
-					state.DeviationTitle = fmt.Sprintf("Service '%s' (id %s) had status '%s' whereas '%s' is expected.",
+					state.DeviationTitle = fmt.Sprintf("%s '%s' (id %s) had status '%s' whereas %s is expected.",
+						kind.label.One,
 						component.Name,
 						state.ServiceId,
-						componentHealthState,
-						state.ExpectedStatus)
+						component.State.HealthState,
+						state.expectedStatusText())
 				}
 			}
 			if !state.FailEarly && completed && state.DeviationTitle != "" {
@@ -257,40 +506,327 @@ func MonitorStatusCheckStatus(ctx context.Context, state *ServiceStatusCheckStat
 				})
 			}
 		} else if state.StatusCheckMode == statusCheckModeAtLeastOnce {
-			if componentHealthState == state.ExpectedStatus {
+			if component != nil && state.isExpectedStatus(component.State.HealthState) {
 				state.StatusCheckSuccess = true
 			}
 			if completed && !state.StatusCheckSuccess {
+				errorKind = errorKindAtLeastOnce
 				checkError = new(action_kit_api.ActionKitError{
-					Title: fmt.Sprintf("Service '%s' (id %s) didn't have status '%s' at least once.",
-						component.Name,
+					Title: fmt.Sprintf("%s '%s' (id %s) didn't have status %s at least once.",
+						kind.label.One,
+						name,
 						state.ServiceId,
-						state.ExpectedStatus),
+						state.expectedStatusText()),
 					Status: extutil.Ptr(action_kit_api.Failed),
 				})
 			}
 		}
 	}
 
+	transitionsError, transitionsSummary := evaluateTransitions(state, kind, component, now, completed)
+	if checkError == nil && transitionsError != nil {
+		checkError = transitionsError
+		errorKind = errorKindTransitions
+	}
+	if missingError != nil {
+		checkError = missingError
+		errorKind = errorKindMissingComponent
+	}
+	state.ErrorKind = errorKind
+	if summary == nil {
+		summary = transitionsSummary
+	} else if transitionsSummary != nil {
+		summary = new(action_kit_api.Summary{
+			Level: summary.Level,
+			Text:  summary.Text + " " + transitionsSummary.Text,
+		})
+	}
+
+	metrics = append(metrics, modeMetrics...)
+
 	return &action_kit_api.StatusResult{
 		Completed: completed,
 		Error:     checkError,
-		Metrics: &[]action_kit_api.Metric{
-			*toMetric(component, now),
-		},
+		Metrics:   &metrics,
+		Summary:   summary,
 	}, nil
 }
 
-func loadServiceComponent(ctx context.Context, state *ServiceStatusCheckState, api GetSnapshotApi) (*Component, error) {
+// evaluateDetectedWithin fails unless the component leaves CLEAR within the detection deadline and reports the
+// time-to-detect once it did.
+func evaluateDetectedWithin(state *ServiceStatusCheckState, kind componentKind, component *Component, now time.Time, completed bool) (*action_kit_api.ActionKitError, *action_kit_api.Summary, []action_kit_api.Metric) {
+	var metrics []action_kit_api.Metric
+	name := observedName(state, component)
+	if component != nil && !state.Detected && isDeviating(component.State.HealthState) {
+		state.Detected = true
+		state.TimeToDetect = now.Sub(state.Start)
+		metrics = append(metrics, *toTimeToDetectMetric(map[string]string{
+			kind.idAttribute:   state.ServiceId,
+			kind.nameAttribute: component.Name,
+		}, state.TimeToDetect, now))
+	}
+	if state.Detected {
+		return nil, timeToDetectSummary(fmt.Sprintf("%s '%s'", strings.ToLower(kind.label.One), name), state.TimeToDetect), metrics
+	}
+	if now.After(state.DetectionDeadline) || completed {
+		return new(action_kit_api.ActionKitError{
+			Title: fmt.Sprintf("%s '%s' (id %s) didn't leave status 'CLEAR' within %s.",
+				kind.label.One,
+				name,
+				state.ServiceId,
+				state.DetectionDeadline.Sub(state.Start).Round(time.Second)),
+			Status: extutil.Ptr(action_kit_api.Failed),
+		}), nil, metrics
+	}
+	return nil, nil, metrics
+}
+
+// evaluateRecoversWithin tolerates deviations from the expected status, but fails if a deviation lasts longer than
+// the recovery window or is still ongoing at the end of the step. Every recovery reports its time-to-recover.
+func evaluateRecoversWithin(state *ServiceStatusCheckState, kind componentKind, component *Component, now time.Time, completed bool) (*action_kit_api.ActionKitError, *action_kit_api.Summary, []action_kit_api.Metric) {
+	var metrics []action_kit_api.Metric
+	name := observedName(state, component)
+	if component == nil {
+		// Nothing observed, an open deviation keeps running.
+	} else if !state.isExpectedStatus(component.State.HealthState) {
+		if state.DeviationStart.IsZero() {
+			state.DeviationStart = now
+		}
+	} else if !state.DeviationStart.IsZero() {
+		state.TimeToRecover = now.Sub(state.DeviationStart)
+		state.DeviationStart = time.Time{}
+		metrics = append(metrics, *toTimeToRecoverMetric(map[string]string{
+			kind.idAttribute:   state.ServiceId,
+			kind.nameAttribute: component.Name,
+		}, state.TimeToRecover, now))
+	}
+
+	if !state.DeviationStart.IsZero() && now.Sub(state.DeviationStart) > state.RecoveryWindow {
+		return new(action_kit_api.ActionKitError{
+			Title: fmt.Sprintf("%s '%s' (id %s) didn't recover to status %s within %s.",
+				kind.label.One,
+				name,
+				state.ServiceId,
+				state.expectedStatusText(),
+				state.RecoveryWindow.Round(time.Second)),
+			Status: extutil.Ptr(action_kit_api.Failed),
+		}), nil, metrics
+	}
+	if !state.DeviationStart.IsZero() && completed {
+		// The recovery window is still open, but the step ends before the recovery can be observed.
+		return new(action_kit_api.ActionKitError{
+			Title: fmt.Sprintf("%s '%s' (id %s) was still deviating from status %s at the end of the step, %s into the recovery window of %s.",
+				kind.label.One,
+				name,
+				state.ServiceId,
+				state.expectedStatusText(),
+				now.Sub(state.DeviationStart).Round(time.Second),
+				state.RecoveryWindow.Round(time.Second)),
+			Status: extutil.Ptr(action_kit_api.Failed),
+		}), nil, metrics
+	}
+
+	var summary *action_kit_api.Summary
+	if state.TimeToRecover > 0 {
+		summary = new(action_kit_api.Summary{
+			Level: action_kit_api.SummaryLevelInfo,
+			Text: fmt.Sprintf("%s '%s' recovered to status %s after %s.",
+				kind.label.One,
+				name,
+				state.expectedStatusText(),
+				state.TimeToRecover.Round(time.Second)),
+		})
+	}
+	return nil, summary, metrics
+}
+
+// evaluatePercentageOfTime counts the polls reporting the expected status and fails at the end of the step if their
+// share is below the minimum percentage.
+func evaluatePercentageOfTime(state *ServiceStatusCheckState, kind componentKind, component *Component, now time.Time, completed bool) (*action_kit_api.ActionKitError, *action_kit_api.Summary, []action_kit_api.Metric) {
+	if component != nil {
+		state.TotalPolls++
+		if state.isExpectedStatus(component.State.HealthState) {
+			state.MatchingPolls++
+		}
+	}
+	if !completed {
+		return nil, nil, nil
+	}
+
+	name := observedName(state, component)
+	var percentage float64
+	if state.TotalPolls > 0 {
+		percentage = float64(state.MatchingPolls) * 100 / float64(state.TotalPolls)
+	}
+	metrics := []action_kit_api.Metric{{
+		Name: new("stackstate_status_percentage"),
+		Metric: map[string]string{
+			kind.idAttribute:   state.ServiceId,
+			kind.nameAttribute: name,
+		},
+		Timestamp: now,
+		Value:     percentage,
+	}}
+	text := fmt.Sprintf("%s '%s' (id %s) had status %s in %.1f%% of %d polls whereas at least %.1f%% are expected.",
+		kind.label.One,
+		name,
+		state.ServiceId,
+		state.expectedStatusText(),
+		percentage,
+		state.TotalPolls,
+		state.MinimumPercentage)
+	if percentage < state.MinimumPercentage {
+		return new(action_kit_api.ActionKitError{
+			Title:  text,
+			Status: extutil.Ptr(action_kit_api.Failed),
+		}), nil, metrics
+	}
+	return nil, new(action_kit_api.Summary{
+		Level: action_kit_api.SummaryLevelInfo,
+		Text:  text,
+	}), metrics
+}
+
+// evaluateTransitions records health state changes and fails once there are more than MaxTransitions of them. At the
+// end of the step the observed transitions are reported as summary.
+func evaluateTransitions(state *ServiceStatusCheckState, kind componentKind, component *Component, now time.Time, completed bool) (*action_kit_api.ActionKitError, *action_kit_api.Summary) {
+	if state.MaxTransitions <= 0 {
+		return nil, nil
+	}
+	name := observedName(state, component)
+	if component != nil {
+		healthState := component.State.HealthState
+		if state.LastHealthState != "" && state.LastHealthState != healthState {
+			state.Transitions = append(state.Transitions, HealthStateTransition{
+				Offset: now.Sub(state.Start).Round(time.Second),
+				From:   state.LastHealthState,
+				To:     healthState,
+			})
+		}
+		state.LastHealthState = healthState
+	}
+
+	if err := transitionsError(state, kind, name); err != nil {
+		return err, nil
+	}
+	if !completed {
+		return nil, nil
+	}
+	if len(state.Transitions) == 0 {
+		return nil, new(action_kit_api.Summary{
+			Level: action_kit_api.SummaryLevelInfo,
+			Text:  fmt.Sprintf("%s '%s' kept status '%s' during the whole step.", kind.label.One, name, state.LastHealthState),
+		})
+	}
+	return nil, new(action_kit_api.Summary{
+		Level: action_kit_api.SummaryLevelInfo,
+		Text: fmt.Sprintf("%s '%s' changed its health state %d times: %s",
+			kind.label.One,
+			name,
+			len(state.Transitions),
+			transitionsText(state.Transitions)),
+	})
+}
+
+// transitionsError fails the check if the component changed its health state more often than allowed.
+func transitionsError(state *ServiceStatusCheckState, kind componentKind, name string) *action_kit_api.ActionKitError {
+	if state.MaxTransitions <= 0 || len(state.Transitions) <= state.MaxTransitions {
+		return nil
+	}
+	return new(action_kit_api.ActionKitError{
+		Title: fmt.Sprintf("%s '%s' (id %s) changed its health state %d times whereas at most %d changes are allowed.",
+			kind.label.One,
+			name,
+			state.ServiceId,
+			len(state.Transitions),
+			state.MaxTransitions),
+		Detail: new(transitionsText(state.Transitions)),
+		Status: extutil.Ptr(action_kit_api.Failed),
+	})
+}
+
+// observedName returns the name of the polled component, or the name the check was prepared with if the poll didn't
+// find the component.
+func observedName(state *ServiceStatusCheckState, component *Component) string {
+	if component == nil {
+		return state.ServiceName
+	}
+	return component.Name
+}
+
+func transitionsText(transitions []HealthStateTransition) string {
+	texts := make([]string, 0, len(transitions))
+	for _, transition := range transitions {
+		texts = append(texts, fmt.Sprintf("%s → %s after %s", transition.From, transition.To, transition.Offset))
+	}
+	return strings.Join(texts, ", ")
+}
+
+// expectedStatuses returns the accepted health states, modes that can't do without one default to CLEAR.
+func (s *ServiceStatusCheckState) expectedStatuses() []string {
+	if len(s.ExpectedStatus) == 0 {
+		return []string{"CLEAR"}
+	}
+	return s.ExpectedStatus
+}
+
+func (s *ServiceStatusCheckState) isExpectedStatus(healthState string) bool {
+	return slices.Contains(s.expectedStatuses(), healthState)
+}
+
+// expectedStatusText renders the accepted health states for messages, e.g. 'CLEAR' or 'DEVIATING'.
+func (s *ServiceStatusCheckState) expectedStatusText() string {
+	quoted := make([]string, 0, len(s.expectedStatuses()))
+	for _, status := range s.expectedStatuses() {
+		quoted = append(quoted, fmt.Sprintf("'%s'", status))
+	}
+	return strings.Join(quoted, " or ")
+}
+
+// isDeviating reports whether StackState reports a failure, UNKNOWN is not considered a detected failure.
+func isDeviating(healthState string) bool {
+	return healthState == "DEVIATING" || healthState == "CRITICAL"
+}
+
+// missingComponent tells the caller of loadServiceComponent to fail or ignore the poll, the other behaviors are
+// handled by loadServiceComponent itself.
+type missingComponent struct {
+	behavior string
+	reason   string
+}
+
+func loadServiceComponent(ctx context.Context, state *ServiceStatusCheckState, api GetSnapshotApi) (*Component, *missingComponent, error) {
 	res, stackStateResponse, err := api.GetServiceSnapshot(ctx, state.ServiceId)
 	if err != nil {
-		return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to retrieve service states from StackState for Service ID %s.", state.ServiceId), err))
+		return nil, nil, new(extension_kit.ToError(fmt.Sprintf("Failed to retrieve service states from StackState for Service ID %s.", state.ServiceId), err))
 	}
 	if !res.IsSuccess() {
 		log.Err(err).Msgf("StackState API responded with unexpected status code %d while retrieving service states for Service ID %s. Full response: %v", res.StatusCode(), state.ServiceId, res.String())
+		reason := fmt.Sprintf("StackState API responded with unexpected status code %d for Service ID %s.", res.StatusCode(), state.ServiceId)
+		return handleMissingComponent(state, missingComponentUnknown, reason)
+	}
+	if len(stackStateResponse.ViewSnapshotResponse.Components) == 0 {
+		if component := resolveComponent(ctx, state, api); component != nil {
+			return component, nil, nil
+		}
+		reason := fmt.Sprintf("StackState returned no components for Service ID %s.", state.ServiceId)
+		return handleMissingComponent(state, missingComponentError, reason)
+	}
+	return &stackStateResponse.ViewSnapshotResponse.Components[0], nil, nil
+}
+
+// handleMissingComponent applies the configured missing component behavior, legacyBehavior is used for experiments
+// that don't configure one.
+func handleMissingComponent(state *ServiceStatusCheckState, legacyBehavior string, reason string) (*Component, *missingComponent, error) {
+	behavior := state.MissingComponent
+	if behavior == "" {
+		behavior = legacyBehavior
+	}
+	switch behavior {
+	case missingComponentUnknown:
 		serviceIdInt, parseErr := strconv.Atoi(state.ServiceId)
 		if parseErr != nil {
-			return nil, new(extension_kit.ToError(fmt.Sprintf("Failed to parse int %s", state.ServiceId), parseErr))
+			return nil, nil, new(extension_kit.ToError(fmt.Sprintf("Failed to parse int %s", state.ServiceId), parseErr))
 		}
 		return &Component{
 			Id:   serviceIdInt,
@@ -299,19 +835,112 @@ func loadServiceComponent(ctx context.Context, state *ServiceStatusCheckState, a
 				HealthState: "UNKNOWN",
 			},
 			Identifiers: []string{fmt.Sprintf("urn:service:/%s:%s:%s", state.ClusterName, state.ServiceName, state.ServiceId)},
-		}, nil
+		}, nil, nil
+	case missingComponentFail, missingComponentIgnore:
+		return nil, &missingComponent{behavior: behavior, reason: reason}, nil
+	default:
+		return nil, nil, new(extension_kit.ToError(reason, nil))
 	}
-	if len(stackStateResponse.ViewSnapshotResponse.Components) == 0 {
-		return nil, new(extension_kit.ToError(fmt.Sprintf("StackState returned no components for Service ID %s.", state.ServiceId), nil))
+}
+
+// resolveComponent looks the component up by its URN, or by its name, cluster and namespace, once its numeric id is
+// gone, e.g. because StackState re-created it. The state continues with the new id.
+func resolveComponent(ctx context.Context, state *ServiceStatusCheckState, api GetSnapshotApi) *Component {
+	for _, query := range resolveComponentQueries(state) {
+		res, stackStateResponse, err := api.QuerySnapshots(ctx, query)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed to resolve component %s with query %s.", state.ServiceId, query)
+			continue
+		}
+		// Ambiguous results are ignored, checking the wrong component is worse than reporting the missing one.
+		if !res.IsSuccess() || len(stackStateResponse.ViewSnapshotResponse.Components) != 1 {
+			continue
+		}
+		component := &stackStateResponse.ViewSnapshotResponse.Components[0]
+		log.Info().Msgf("Component %s is gone, continuing with component %d resolved by query %s.", state.ServiceId, component.Id, query)
+		state.ServiceId = strconv.Itoa(component.Id)
+		return component
+	}
+	return resolveReplacement(ctx, state, api)
+}
+
+// resolveReplacement looks for the component replacing a gone component of an owner, e.g. the pod a ReplicaSet
+// created for a killed pod. The replacement has a new name, so the state continues with its id, name and URN.
+func resolveReplacement(ctx context.Context, state *ServiceStatusCheckState, api GetSnapshotApi) *Component {
+	query := ownerQuery(state)
+	if query == "" {
+		return nil
+	}
+	res, stackStateResponse, err := api.QuerySnapshots(ctx, query)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed to resolve the replacement of component %s with query %s.", state.ServiceId, query)
+		return nil
+	}
+	if !res.IsSuccess() {
+		return nil
+	}
+	kind, _ := componentKindOf(state.TargetType)
+	var replacements []*Component
+	for i, component := range stackStateResponse.ViewSnapshotResponse.Components {
+		// The name pattern of the query also matches longer names of other owners.
+		if kind.ownerPrefix(component.Name) != state.OwnerPrefix {
+			continue
+		}
+		if id := strconv.Itoa(component.Id); id != state.ServiceId && !slices.Contains(state.Siblings, id) {
+			replacements = append(replacements, &stackStateResponse.ViewSnapshotResponse.Components[i])
+		}
+	}
+	// As above, an ambiguous replacement is ignored.
+	if len(replacements) != 1 {
+		return nil
+	}
+	component := replacements[0]
+	log.Info().Msgf("Component %s is gone, continuing with its replacement %d (%s).", state.ServiceId, component.Id, component.Name)
+	state.ServiceId = strconv.Itoa(component.Id)
+	state.ServiceName = component.Name
+	state.Urn = componentUrn(*component)
+	return component
+}
+
+// ownerQuery finds the components sharing the owner prefix in the namespace of the checked component.
+func ownerQuery(state *ServiceStatusCheckState) string {
+	kind, ok := componentKindOf(state.TargetType)
+	if !ok || state.OwnerPrefix == "" || state.ClusterName == "" || state.Namespace == "" {
+		return ""
+	}
+	return fmt.Sprintf("(type = %s AND name = %s AND label = %s AND label = %s)",
+		stqlString(kind.componentType),
+		stqlString(state.OwnerPrefix+"*"),
+		stqlString("cluster-name:"+state.ClusterName),
+		stqlString("namespace:"+state.Namespace))
+}
+
+func resolveComponentQueries(state *ServiceStatusCheckState) []string {
+	kind, ok := componentKindOf(state.TargetType)
+	if !ok {
+		return nil
+	}
+	var queries []string
+	if state.Urn != "" {
+		queries = append(queries, fmt.Sprintf("(identifier = %s)", stqlString(state.Urn)))
+	}
+	if kind.query != "" {
+		queries = append(queries, fmt.Sprintf("(%s) AND name = %s", kind.query, stqlString(state.ServiceName)))
+	} else if kind.kubernetes && state.ClusterName != "" && state.Namespace != "" {
+		queries = append(queries, fmt.Sprintf("(type = %s AND name = %s AND label = %s AND label = %s)",
+			stqlString(kind.componentType),
+			stqlString(state.ServiceName),
+			stqlString("cluster-name:"+state.ClusterName),
+			stqlString("namespace:"+state.Namespace)))
 	}
-	return &stackStateResponse.ViewSnapshotResponse.Components[0], nil
+	return queries
 }
 
-func toMetric(service *Component, now time.Time) *action_kit_api.Metric {
+func toMetric(service *Component, kind componentKind, now time.Time) *action_kit_api.Metric {
 	var tooltip string
 	var state string
 
-	tooltip = fmt.Sprintf("Service status is: %s", service.State.HealthState)
+	tooltip = fmt.Sprintf("%s status is: %s", kind.label.One, service.State.HealthState)
 	if service.State.HealthState == "UNKNOWN" {
 		state = "warn"
 	} else if service.State.HealthState == "CLEAR" {
@@ -335,11 +964,11 @@ func toMetric(service *Component, now time.Time) *action_kit_api.Metric {
 	return new(action_kit_api.Metric{
 		Name: new("stackstate_service_status"),
 		Metric: map[string]string{
-			attributeServiceId:     strconv.Itoa(service.Id),
-			attributeK8ServiceName: service.Name,
-			attributeState:         state,
-			attributeTooltip:       tooltip,
-			attributeUrl:           serviceUrl,
+			kind.idAttribute:   strconv.Itoa(service.Id),
+			kind.nameAttribute: service.Name,
+			attributeState:     state,
+			attributeTooltip:   tooltip,
+			attributeUrl:       serviceUrl,
 		},
 		Timestamp: now,
 		Value:     0,
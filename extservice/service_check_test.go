@@ -23,6 +23,11 @@ func (m *getSnapshotApiMock) GetServiceSnapshot(ctx context.Context, serviceId s
 	return args.Get(0).(*resty.Response), args.Get(1).(ViewSnapshotResponseWrapper), args.Error(2)
 }
 
+func (m *getSnapshotApiMock) QuerySnapshots(ctx context.Context, query string) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(*resty.Response), args.Get(1).(ViewSnapshotResponseWrapper), args.Error(2)
+}
+
 var action = NewServiceStatusCheckAction()
 
 func TestServiceCheck(t *testing.T) {
@@ -60,7 +65,7 @@ func TestServiceCheck(t *testing.T) {
 		require.Equal(t, state.ServiceId, "123")
 		require.Equal(t, state.ServiceName, "test")
 		require.Equal(t, state.ClusterName, "test-cluster")
-		require.Equal(t, state.ExpectedStatus, "200")
+		require.Equal(t, state.ExpectedStatus, []string{"200"})
 		require.Equal(t, state.StatusCheckMode, statusCheckModeAtLeastOnce)
 		require.Equal(t, state.StatusCheckSuccess, false)
 	})
@@ -95,7 +100,7 @@ func TestServiceCheck(t *testing.T) {
 		require.Equal(t, state.ServiceId, "123")
 		require.Equal(t, state.ServiceName, "test")
 		require.Equal(t, state.ClusterName, "test-cluster")
-		require.Equal(t, state.ExpectedStatus, "200")
+		require.Equal(t, state.ExpectedStatus, []string{"200"})
 		require.Equal(t, state.StatusCheckMode, statusCheckModeAllTheTime)
 		require.Equal(t, state.StatusCheckSuccess, true)
 	})
@@ -266,7 +271,7 @@ func serviceCheckState(mode string) ServiceStatusCheckState {
 	state.ServiceId = "123"
 	state.ServiceName = "test"
 	state.ClusterName = "test-cluster"
-	state.ExpectedStatus = "CLEAR"
+	state.ExpectedStatus = []string{"CLEAR"}
 	state.StatusCheckMode = mode
 	state.StatusCheckSuccess = mode == statusCheckModeAllTheTime
 	state.FailEarly = true // matches the production default; 'All the time' fails fast
@@ -283,6 +288,15 @@ func apiResponseWithStatus(status int) *resty.Response {
 	}
 }
 
+// pollStatusCheck runs one status check poll that finds the component with the given health state.
+func pollStatusCheck(t *testing.T, state *ServiceStatusCheckState, healthState string) *action_kit_api.StatusResult {
+	mockedApi := new(getSnapshotApiMock)
+	mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), serviceResponseWithState(healthState), nil)
+	status, err := MonitorStatusCheckStatus(context.TODO(), state, mockedApi)
+	require.NoError(t, err)
+	return status
+}
+
 func serviceResponseWithState(state string) ViewSnapshotResponseWrapper {
 	return ViewSnapshotResponseWrapper{
 		ViewSnapshotResponse: ViewSnapshotResponse{
@@ -301,3 +315,471 @@ func serviceResponseWithState(state string) ViewSnapshotResponseWrapper {
 		},
 	}
 }
+
+func TestServiceCheckDetectedWithin(t *testing.T) {
+	t.Run("detected within deadline", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeDetectedWithin)
+		state.Start = time.Now().Add(-5 * time.Second)
+		state.DetectionDeadline = time.Now().Add(1 * time.Minute)
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), serviceResponseWithState("CLEAR"), nil)
+
+		status, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.Nil(t, status.Error)
+		require.False(t, state.Detected)
+
+		mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Unset()
+		mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), serviceResponseWithState("CRITICAL"), nil)
+
+		status, err = MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.Nil(t, status.Error)
+		require.True(t, state.Detected)
+		require.GreaterOrEqual(t, state.TimeToDetect, 5*time.Second)
+		require.Len(t, *status.Metrics, 2)
+		require.Equal(t, "stackstate_time_to_detect", *(*status.Metrics)[1].Name)
+		require.NotNil(t, status.Summary)
+
+		state.End = time.Now().Add(-1 * time.Hour)
+		status, err = MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.True(t, status.Completed)
+		require.Nil(t, status.Error)
+		require.Len(t, *status.Metrics, 1)
+	})
+
+	t.Run("not detected within deadline", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeDetectedWithin)
+		state.Start = time.Now().Add(-1 * time.Minute)
+		state.DetectionDeadline = time.Now().Add(-1 * time.Second)
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), serviceResponseWithState("CLEAR"), nil)
+
+		status, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.False(t, status.Completed)
+		require.NotNil(t, status.Error)
+		require.Contains(t, status.Error.Title, "didn't leave status 'CLEAR' within")
+	})
+
+	t.Run("Prepare uses the detection deadline", func(t *testing.T) {
+		request := extutil.JsonMangle(action_kit_api.PrepareActionRequestBody{
+			Config: map[string]any{
+				"duration":          1000 * 60,
+				"statusCheckMode":   statusCheckModeDetectedWithin,
+				"detectionDeadline": 1000 * 10,
+			},
+			Target: &action_kit_api.Target{
+				Attributes: map[string][]string{
+					"stackstate.service.id": {"123"},
+					"k8s.service.name":      {"test"},
+					"k8s.cluster-name":      {"test-cluster"},
+				},
+			},
+		})
+		state := action.NewEmptyState()
+
+		_, err := action.Prepare(context.TODO(), &state, request)
+		require.NoError(t, err)
+		require.Equal(t, 10*time.Second, state.DetectionDeadline.Sub(state.Start))
+	})
+}
+
+func TestServiceCheckRecoversWithin(t *testing.T) {
+	t.Run("recovers within window", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeRecoversWithin)
+		state.RecoveryWindow = 1 * time.Minute
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), serviceResponseWithState("DEVIATING"), nil)
+
+		status, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.Nil(t, status.Error)
+		require.False(t, state.DeviationStart.IsZero())
+
+		state.DeviationStart = time.Now().Add(-20 * time.Second)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Unset()
+		mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), serviceResponseWithState("CLEAR"), nil)
+
+		status, err = MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.Nil(t, status.Error)
+		require.True(t, state.DeviationStart.IsZero())
+		require.GreaterOrEqual(t, state.TimeToRecover, 20*time.Second)
+		require.Len(t, *status.Metrics, 2)
+		require.Equal(t, "stackstate_time_to_recover", *(*status.Metrics)[1].Name)
+		require.Contains(t, status.Summary.Text, "recovered to status 'CLEAR' after 20s")
+
+		state.End = time.Now().Add(-1 * time.Hour)
+		status, err = MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.True(t, status.Completed)
+		require.Nil(t, status.Error)
+	})
+
+	t.Run("doesn't recover within window", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeRecoversWithin)
+		state.RecoveryWindow = 10 * time.Second
+		state.DeviationStart = time.Now().Add(-11 * time.Second)
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), serviceResponseWithState("CRITICAL"), nil)
+
+		status, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.False(t, status.Completed)
+		require.NotNil(t, status.Error)
+		require.Contains(t, status.Error.Title, "didn't recover to status 'CLEAR' within 10s")
+	})
+
+	t.Run("still deviating at the end of the step", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeRecoversWithin)
+		state.RecoveryWindow = 1 * time.Minute
+		state.DeviationStart = time.Now().Add(-20 * time.Second)
+		state.End = time.Now().Add(-1 * time.Second)
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), serviceResponseWithState("DEVIATING"), nil)
+
+		status, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.True(t, status.Completed)
+		require.NotNil(t, status.Error)
+		require.Equal(t, "Service 'service1' (id 123) was still deviating from status 'CLEAR' at the end of the step, 20s into the recovery window of 1m0s.", status.Error.Title)
+		require.NotContains(t, status.Error.Title, "didn't recover")
+	})
+}
+
+func TestServiceCheckPercentageOfTime(t *testing.T) {
+	t.Run("percentage reached", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModePercentageOfTime)
+		state.MinimumPercentage = 75
+		for _, healthState := range []string{"CLEAR", "DEVIATING", "CLEAR"} {
+			status := pollStatusCheck(t, &state, healthState)
+			require.Nil(t, status.Error)
+			require.Nil(t, status.Summary)
+		}
+
+		state.End = time.Now().Add(-1 * time.Hour)
+		status := pollStatusCheck(t, &state, "CLEAR")
+		require.True(t, status.Completed)
+		require.Nil(t, status.Error)
+		require.Contains(t, status.Summary.Text, "75.0% of 4 polls")
+		require.Equal(t, 75.0, (*status.Metrics)[1].Value)
+	})
+
+	t.Run("percentage missed", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModePercentageOfTime)
+		state.MinimumPercentage = 95
+		pollStatusCheck(t, &state, "CLEAR")
+
+		state.End = time.Now().Add(-1 * time.Hour)
+		status := pollStatusCheck(t, &state, "CRITICAL")
+		require.True(t, status.Completed)
+		require.NotNil(t, status.Error)
+		require.Contains(t, status.Error.Title, "50.0% of 2 polls")
+	})
+}
+
+func TestServiceCheckMultipleExpectedStatuses(t *testing.T) {
+	config.Config.ApiBaseUrl = "http://integration-test.invalid/api"
+
+	prepare := func(t *testing.T, expectedStatus any) ServiceStatusCheckState {
+		request := extutil.JsonMangle(action_kit_api.PrepareActionRequestBody{
+			Config: map[string]any{
+				"duration":        1000 * 60,
+				"expectedStatus":  expectedStatus,
+				"statusCheckMode": "allTheTime",
+			},
+			Target: &action_kit_api.Target{
+				Attributes: map[string][]string{
+					"stackstate.service.id": {"123"},
+					"k8s.service.name":      {"test"},
+				},
+			},
+		})
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		_, err := action.Prepare(context.TODO(), &state, request)
+		require.NoError(t, err)
+		return state
+	}
+
+	t.Run("Prepare joins expected statuses", func(t *testing.T) {
+		state := prepare(t, []string{"CLEAR", "", "DEVIATING"})
+
+		require.Equal(t, []string{"CLEAR", "DEVIATING"}, state.ExpectedStatus)
+	})
+
+	t.Run("Prepare keeps a legacy single expected status", func(t *testing.T) {
+		state := prepare(t, "DEVIATING")
+
+		require.Equal(t, []string{"DEVIATING"}, state.ExpectedStatus)
+	})
+
+	t.Run("Prepare drops a legacy empty expected status", func(t *testing.T) {
+		state := prepare(t, "")
+
+		require.Empty(t, state.ExpectedStatus)
+	})
+
+	t.Run("allTheTime accepts any expected status", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.ExpectedStatus = []string{"CLEAR", "DEVIATING"}
+
+		require.Nil(t, pollStatusCheck(t, &state, "CLEAR").Error)
+		require.Nil(t, pollStatusCheck(t, &state, "DEVIATING").Error)
+
+		status := pollStatusCheck(t, &state, "CRITICAL")
+		require.NotNil(t, status.Error)
+		require.Contains(t, status.Error.Title, "whereas 'CLEAR' or 'DEVIATING' is expected")
+	})
+
+	t.Run("atLeastOnce accepts any expected status", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAtLeastOnce)
+		state.ExpectedStatus = []string{"CLEAR", "DEVIATING"}
+		state.End = time.Now().Add(-1 * time.Hour)
+
+		status := pollStatusCheck(t, &state, "DEVIATING")
+		require.True(t, status.Completed)
+		require.Nil(t, status.Error)
+	})
+}
+
+func TestServiceCheckGracePeriod(t *testing.T) {
+	t.Run("short deviation is tolerated", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.GracePeriod = 10 * time.Second
+
+		require.Nil(t, pollStatusCheck(t, &state, "DEVIATING").Error)
+		require.False(t, state.DeviationStart.IsZero())
+		require.Nil(t, pollStatusCheck(t, &state, "CLEAR").Error)
+		require.True(t, state.DeviationStart.IsZero())
+	})
+
+	t.Run("deviation exceeding the grace period fails", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.GracePeriod = 10 * time.Second
+
+		require.Nil(t, pollStatusCheck(t, &state, "DEVIATING").Error)
+		state.DeviationStart = time.Now().Add(-11 * time.Second)
+		status := pollStatusCheck(t, &state, "DEVIATING")
+		require.NotNil(t, status.Error)
+		require.Equal(t, action_kit_api.Failed, *status.Error.Status)
+	})
+
+	t.Run("fail at end only reports deviations exceeding the grace period", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.GracePeriod = 10 * time.Second
+		state.FailEarly = false
+
+		require.Nil(t, pollStatusCheck(t, &state, "CRITICAL").Error)
+		state.End = time.Now().Add(-1 * time.Hour)
+		status := pollStatusCheck(t, &state, "CLEAR")
+		require.True(t, status.Completed)
+		require.Nil(t, status.Error)
+	})
+
+	t.Run("without grace period the first deviation fails", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+
+		require.NotNil(t, pollStatusCheck(t, &state, "DEVIATING").Error)
+	})
+}
+
+func TestServiceCheckFlapDetection(t *testing.T) {
+	t.Run("fails when the health state flaps too often", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAtLeastOnce)
+		state.Start = time.Now()
+		state.MaxTransitions = 2
+
+		for _, healthState := range []string{"CLEAR", "DEVIATING", "CLEAR"} {
+			require.Nil(t, pollStatusCheck(t, &state, healthState).Error)
+		}
+		status := pollStatusCheck(t, &state, "DEVIATING")
+		require.NotNil(t, status.Error)
+		require.Equal(t, action_kit_api.Failed, *status.Error.Status)
+		require.Contains(t, status.Error.Title, "changed its health state 3 times whereas at most 2 changes are allowed")
+		require.Contains(t, *status.Error.Detail, "CLEAR → DEVIATING after 0s, DEVIATING → CLEAR after 0s")
+	})
+
+	t.Run("reports transitions at the end", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAtLeastOnce)
+		state.Start = time.Now()
+		state.MaxTransitions = 2
+
+		require.Nil(t, pollStatusCheck(t, &state, "CLEAR").Error)
+		require.Nil(t, pollStatusCheck(t, &state, "DEVIATING").Error)
+		state.End = time.Now().Add(-1 * time.Hour)
+		status := pollStatusCheck(t, &state, "CLEAR")
+		require.True(t, status.Completed)
+		require.Nil(t, status.Error)
+		require.Equal(t, "Service 'service1' changed its health state 2 times: CLEAR → DEVIATING after 0s, DEVIATING → CLEAR after 0s", status.Summary.Text)
+	})
+
+	t.Run("appends the transitions to the summary of the mode", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeRecoversWithin)
+		state.Start = time.Now()
+		state.RecoveryWindow = time.Minute
+		state.MaxTransitions = 2
+
+		require.Nil(t, pollStatusCheck(t, &state, "CLEAR").Error)
+		require.Nil(t, pollStatusCheck(t, &state, "DEVIATING").Error)
+		state.End = time.Now().Add(-1 * time.Hour)
+		status := pollStatusCheck(t, &state, "CLEAR")
+		require.True(t, status.Completed)
+		require.Nil(t, status.Error)
+		require.Equal(t, "Service 'service1' recovered to status 'CLEAR' after 0s. Service 'service1' changed its health state 2 times: CLEAR → DEVIATING after 0s, DEVIATING → CLEAR after 0s", status.Summary.Text)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAtLeastOnce)
+
+		for _, healthState := range []string{"CLEAR", "DEVIATING", "CLEAR", "DEVIATING"} {
+			require.Nil(t, pollStatusCheck(t, &state, healthState).Error)
+		}
+		require.Empty(t, state.Transitions)
+	})
+}
+
+func TestServiceCheckResolvesRecreatedComponent(t *testing.T) {
+	noComponents := ViewSnapshotResponseWrapper{}
+	recreated := serviceResponseWithState("DEVIATING")
+	recreated.ViewSnapshotResponse.Components[0].Id = 456
+
+	t.Run("by urn", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.ExpectedStatus = []string{"DEVIATING"}
+		state.Urn = "urn:service:/test-cluster:test"
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, "123").Return(apiResponseWithStatus(200), noComponents, nil)
+		mockedApi.On("QuerySnapshots", mock.Anything, `(identifier = "urn:service:/test-cluster:test")`).Return(apiResponseWithStatus(200), recreated, nil)
+
+		status, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+
+		require.NoError(t, err)
+		require.Nil(t, status.Error)
+		require.Equal(t, "456", state.ServiceId)
+	})
+
+	t.Run("by cluster, namespace and name", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.ExpectedStatus = []string{"DEVIATING"}
+		state.TargetType = serviceTargetType
+		state.Namespace = "shop"
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, "123").Return(apiResponseWithStatus(200), noComponents, nil)
+		mockedApi.On("QuerySnapshots", mock.Anything, `(type = "service" AND name = "test" AND label = "cluster-name:test-cluster" AND label = "namespace:shop")`).Return(apiResponseWithStatus(200), recreated, nil)
+
+		_, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+
+		require.NoError(t, err)
+		require.Equal(t, "456", state.ServiceId)
+	})
+
+	t.Run("fails if the component can't be resolved", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.Urn = "urn:service:/test-cluster:test"
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, "123").Return(apiResponseWithStatus(200), noComponents, nil)
+		mockedApi.On("QuerySnapshots", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), noComponents, nil)
+
+		_, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+
+		require.ErrorContains(t, err, "StackState returned no components for Service ID 123.")
+		require.Equal(t, "123", state.ServiceId)
+	})
+}
+
+func TestToServiceUsesUrn(t *testing.T) {
+	target := toService(Component{
+		Id:          123,
+		Name:        "test",
+		Identifiers: []string{"other:identifier", "urn:service:/test-cluster:test"},
+	})
+
+	require.Equal(t, "urn:service:/test-cluster:test", target.Id)
+	require.Equal(t, []string{"123"}, target.Attributes[attributeServiceId])
+	require.Equal(t, []string{"urn:service:/test-cluster:test"}, target.Attributes[attributeServiceUrn])
+}
+
+func TestServiceCheckMissingComponent(t *testing.T) {
+	poll := func(t *testing.T, behavior string, response *resty.Response) (*action_kit_api.StatusResult, error) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.MissingComponent = behavior
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, "123").Return(response, ViewSnapshotResponseWrapper{}, nil)
+		mockedApi.On("QuerySnapshots", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+		return MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+	}
+
+	t.Run("legacy behavior", func(t *testing.T) {
+		_, err := poll(t, "", apiResponseWithStatus(200))
+		require.ErrorContains(t, err, "StackState returned no components for Service ID 123.")
+
+		status, err := poll(t, "", apiResponseWithStatus(500))
+		require.NoError(t, err)
+		require.NotNil(t, status.Error)
+		require.Contains(t, status.Error.Title, "has status 'UNKNOWN'")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := poll(t, missingComponentError, apiResponseWithStatus(500))
+		require.ErrorContains(t, err, "StackState API responded with unexpected status code 500 for Service ID 123.")
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		status, err := poll(t, missingComponentFail, apiResponseWithStatus(200))
+		require.NoError(t, err)
+		require.True(t, status.Completed)
+		require.Equal(t, action_kit_api.Failed, *status.Error.Status)
+		require.Equal(t, "StackState returned no components for Service ID 123.", status.Error.Title)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		status, err := poll(t, missingComponentUnknown, apiResponseWithStatus(200))
+		require.NoError(t, err)
+		require.Contains(t, status.Error.Title, "has status 'UNKNOWN'")
+	})
+
+	t.Run("ignore", func(t *testing.T) {
+		status, err := poll(t, missingComponentIgnore, apiResponseWithStatus(500))
+		require.NoError(t, err)
+		require.False(t, status.Completed)
+		require.Nil(t, status.Error)
+	})
+
+	t.Run("ignore on the last poll still reports an earlier deviation", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.MissingComponent = missingComponentIgnore
+		state.FailEarly = false
+
+		require.Nil(t, pollStatusCheck(t, &state, "DEVIATING").Error)
+
+		state.End = time.Now().Add(-1 * time.Hour)
+		missingApi := new(getSnapshotApiMock)
+		missingApi.On("GetServiceSnapshot", mock.Anything, "123").Return(apiResponseWithStatus(500), ViewSnapshotResponseWrapper{}, nil)
+		missingApi.On("QuerySnapshots", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+		status, err := MonitorStatusCheckStatus(context.TODO(), &state, missingApi)
+		require.NoError(t, err)
+		require.True(t, status.Completed)
+		require.NotNil(t, status.Error)
+		require.Equal(t, action_kit_api.Failed, *status.Error.Status)
+		require.Contains(t, status.Error.Title, "had status 'DEVIATING'")
+	})
+
+	t.Run("fail keeps the summary", func(t *testing.T) {
+		state := serviceCheckState(statusCheckModeAllTheTime)
+		state.MissingComponent = missingComponentFail
+		state.MaxTransitions = 3
+		state.LastHealthState = "CLEAR"
+		mockedApi := new(getSnapshotApiMock)
+		mockedApi.On("GetServiceSnapshot", mock.Anything, "123").Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+		mockedApi.On("QuerySnapshots", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+
+		status, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+		require.NoError(t, err)
+		require.True(t, status.Completed)
+		require.Equal(t, "StackState returned no components for Service ID 123.", status.Error.Title)
+		require.NotNil(t, status.Summary)
+		require.Contains(t, status.Summary.Text, "kept status 'CLEAR'")
+	})
+}
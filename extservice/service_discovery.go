@@ -20,14 +20,16 @@ import (
 	"github.com/steadybit/extension-kit/extbuild"
 	"github.com/steadybit/extension-stackstate/config"
 	"strconv"
-	"time"
 )
 
-type serviceDiscovery struct{}
+type serviceDiscovery struct {
+	lastKnown lastKnownTargets
+}
 
 var (
-	_ discovery_kit_sdk.TargetDescriber    = (*serviceDiscovery)(nil)
-	_ discovery_kit_sdk.AttributeDescriber = (*serviceDiscovery)(nil)
+	_ discovery_kit_sdk.TargetDescriber          = (*serviceDiscovery)(nil)
+	_ discovery_kit_sdk.AttributeDescriber       = (*serviceDiscovery)(nil)
+	_ discovery_kit_sdk.EnrichmentRulesDescriber = (*serviceDiscovery)(nil)
 )
 
 type GetSnapshotsApi interface {
@@ -38,7 +40,7 @@ func NewServiceDiscovery() discovery_kit_sdk.TargetDiscovery {
 	discovery := &serviceDiscovery{}
 	return discovery_kit_sdk.NewCachedTargetDiscovery(discovery,
 		discovery_kit_sdk.WithRefreshTargetsNow(),
-		discovery_kit_sdk.WithRefreshTargetsInterval(context.Background(), 1*time.Minute),
+		discovery_kit_sdk.WithRefreshTargetsInterval(context.Background(), config.Config.DiscoveryInterval),
 	)
 }
 
@@ -46,7 +48,7 @@ func (d *serviceDiscovery) Describe() discovery_kit_api.DiscoveryDescription {
 	return discovery_kit_api.DiscoveryDescription{
 		Id: serviceTargetType,
 		Discover: discovery_kit_api.DescribingEndpointReferenceWithCallInterval{
-			CallInterval: new("1m"),
+			CallInterval: new(config.Config.DiscoveryInterval.String()),
 		},
 	}
 }
@@ -63,6 +65,8 @@ func (d *serviceDiscovery) DescribeTarget() discovery_kit_api.TargetDescription
 				{Attribute: attributeK8ServiceName},
 				{Attribute: attributeK8Namespace},
 				{Attribute: attributeK8ClusterName},
+				{Attribute: attributeServiceDependsOn},
+				{Attribute: attributeServiceUsedBy},
 			},
 			OrderBy: []discovery_kit_api.OrderBy{
 				{
@@ -94,52 +98,135 @@ func (d *serviceDiscovery) DescribeAttributes() []discovery_kit_api.AttributeDes
 				One:   "Cluster name",
 				Other: "Cluster names",
 			},
+		}, {
+			Attribute: attributeServiceDependsOn,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Depends on service",
+				Other: "Depends on services",
+			},
+		}, {
+			Attribute: attributeServiceUsedBy,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "Used by service",
+				Other: "Used by services",
+			},
+		}, {
+			Attribute: attributeHealthState,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "StackState health state",
+				Other: "StackState health states",
+			},
+		}, {
+			Attribute: attributePropagatedHealthState,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "StackState propagated health state",
+				Other: "StackState propagated health states",
+			},
+		}, {
+			Attribute: attributeLayer,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "StackState layer",
+				Other: "StackState layers",
+			},
+		}, {
+			Attribute: attributeDomain,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "StackState domain",
+				Other: "StackState domains",
+			},
+		}, {
+			Attribute: attributeComponentType,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "StackState component type",
+				Other: "StackState component types",
+			},
+		}, {
+			Attribute: attributeTag,
+			Label: discovery_kit_api.PluralLabel{
+				One:   "StackState tag",
+				Other: "StackState tags",
+			},
 		},
 	}
 }
 
+func (d *serviceDiscovery) DescribeEnrichmentRules() []discovery_kit_api.TargetEnrichmentRule {
+	return enrichmentRules(serviceKind)
+}
+
 func (d *serviceDiscovery) DiscoverTargets(ctx context.Context) ([]discovery_kit_api.Target, error) {
-	return getAllServices(ctx, Client), nil
+	return d.lastKnown.keep(getAllServices(ctx, Client))
 }
 
-func getAllServices(ctx context.Context, api GetSnapshotsApi) []discovery_kit_api.Target {
+func getAllServices(ctx context.Context, api GetSnapshotsApi) ([]discovery_kit_api.Target, error) {
 	result := make([]discovery_kit_api.Target, 0, 500)
 	res, stackStateResponse, err := api.GetServiceSnapshots(ctx)
 
 	if err != nil {
-		log.Err(err).Msgf("Failed to retrieve service states from Stack State.")
-		return result
+		return nil, fmt.Errorf("failed to retrieve service states from StackState: %w", err)
 	}
 
 	if res.StatusCode() != 200 {
-		log.Error().Msgf("StackState API responded with unexpected status code %d while retrieving service states. Full response: %v",
-			res.StatusCode(),
-			res.String())
-		return result
+		return nil, fmt.Errorf("StackState API responded with unexpected status code %d while retrieving service states", res.StatusCode())
 	}
 
 	log.Trace().Msgf("Stackstate response: %v", stackStateResponse.ViewSnapshotResponse.Components)
 
 	if len(stackStateResponse.ViewSnapshotResponse.Components) > 0 {
+		dependencies := dependenciesOf(stackStateResponse.ViewSnapshotResponse)
 		for _, component := range stackStateResponse.ViewSnapshotResponse.Components {
-			result = append(result, toService(component))
+			if inDiscoveryScope(component) {
+				target := toService(component)
+				addDependencyAttributes(target.Attributes, dependencies[component.Id])
+				result = append(result, target)
+			}
 		}
 	}
-	return discovery_kit_commons.ApplyAttributeExcludes(result, config.Config.DiscoveryAttributesExcludesService)
+	return discovery_kit_commons.ApplyAttributeExcludes(result, config.Config.DiscoveryAttributesExcludesService), nil
 }
 
 func toService(service Component) discovery_kit_api.Target {
-	clusterName := strings.TrimPrefix(service.Properties.ClusterNameIdentifier, "urn:cluster:/kubernetes:")
-	namespace := strings.TrimPrefix(service.Properties.NamespaceIdentifier, fmt.Sprintf("urn:kubernetes:/%v:namespace/", clusterName))
+	clusterName, namespace := clusterAndNamespace(service)
+	attributes := map[string][]string{
+		attributeServiceId:     {strconv.Itoa(service.Id)},
+		attributeK8ServiceName: {service.Name},
+		attributeK8Namespace:   {namespace},
+		attributeK8ClusterName: {clusterName},
+	}
+	if urn := componentUrn(service); urn != "" {
+		attributes[attributeServiceUrn] = []string{urn}
+	}
+	addComponentAttributes(attributes, service)
 	return discovery_kit_api.Target{
-		Id:         strconv.Itoa(service.Id),
+		Id:         targetId(service),
 		Label:      service.Name,
 		TargetType: serviceTargetType,
-		Attributes: map[string][]string{
-			attributeServiceId:     {strconv.Itoa(service.Id)},
-			attributeK8ServiceName: {service.Name},
-			attributeK8Namespace:   {namespace},
-			attributeK8ClusterName: {clusterName},
-		},
+		Attributes: attributes,
+	}
+}
+
+// clusterAndNamespace parses the Kubernetes cluster and namespace names from the component's identifier properties.
+func clusterAndNamespace(component Component) (string, string) {
+	clusterName := strings.TrimPrefix(component.Properties.ClusterNameIdentifier, "urn:cluster:/kubernetes:")
+	namespace := strings.TrimPrefix(component.Properties.NamespaceIdentifier, fmt.Sprintf("urn:kubernetes:/%v:namespace/", clusterName))
+	return clusterName, namespace
+}
+
+// componentUrn returns the component's URN identifier, which, unlike the numeric id, stays stable when StackState
+// re-creates the component.
+func componentUrn(component Component) string {
+	for _, identifier := range component.Identifiers {
+		if strings.HasPrefix(identifier, "urn:") {
+			return identifier
+		}
+	}
+	return ""
+}
+
+// targetId identifies the target by its URN and falls back to the numeric id for components without one.
+func targetId(component Component) string {
+	if urn := componentUrn(component); urn != "" {
+		return urn
 	}
+	return strconv.Itoa(component.Id)
 }
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	attributeServiceDependsOn = "stackstate.service.depends-on"
+	attributeServiceUsedBy    = "stackstate.service.used-by"
+)
+
+// serviceDependencies holds the keys of the services a service depends on and is used by, see dependencyKey.
+type serviceDependencies struct {
+	dependsOn []string
+	usedBy    []string
+}
+
+// dependenciesOf derives the dependencies between the components of the response from its relations, keyed by
+// component id. Relations to components that are not part of the response are ignored.
+func dependenciesOf(response ViewSnapshotResponse) map[int]*serviceDependencies {
+	keys := make(map[int]string, len(response.Components))
+	for _, component := range response.Components {
+		keys[component.Id] = dependencyKey(component)
+	}
+	result := make(map[int]*serviceDependencies)
+	dependencies := func(id int) *serviceDependencies {
+		if result[id] == nil {
+			result[id] = &serviceDependencies{}
+		}
+		return result[id]
+	}
+	addDependency := func(dependent int, dependency int) {
+		dependentKey, dependentFound := keys[dependent]
+		dependencyKey, dependencyFound := keys[dependency]
+		if !dependentFound || !dependencyFound || dependent == dependency {
+			return
+		}
+		dependencies(dependent).dependsOn = append(dependencies(dependent).dependsOn, dependencyKey)
+		dependencies(dependency).usedBy = append(dependencies(dependency).usedBy, dependentKey)
+	}
+	for _, relation := range response.Relations {
+		switch relation.DependencyDirection {
+		case "NONE":
+		case "BOTH":
+			addDependency(relation.Source, relation.Target)
+			addDependency(relation.Target, relation.Source)
+		default:
+			addDependency(relation.Source, relation.Target)
+		}
+	}
+	for _, dependency := range result {
+		slices.Sort(dependency.dependsOn)
+		dependency.dependsOn = slices.Compact(dependency.dependsOn)
+		slices.Sort(dependency.usedBy)
+		dependency.usedBy = slices.Compact(dependency.usedBy)
+	}
+	return result
+}
+
+// dependencyKey identifies a service as cluster/namespace/name, because services with the same name exist in many
+// namespaces and clusters. Components without the Kubernetes properties are identified by their target id.
+func dependencyKey(component Component) string {
+	clusterName, namespace := clusterAndNamespace(component)
+	if clusterName == "" || namespace == "" {
+		return targetId(component)
+	}
+	return fmt.Sprintf("%s/%s/%s", clusterName, namespace, component.Name)
+}
+
+// addDependencyAttributes adds the upstream and downstream services, so e.g. all services using a database can be
+// targeted by stackstate.service.depends-on="<cluster>/<namespace>/<name>".
+func addDependencyAttributes(attributes map[string][]string, dependencies *serviceDependencies) {
+	if dependencies == nil {
+		return
+	}
+	if len(dependencies.dependsOn) > 0 {
+		attributes[attributeServiceDependsOn] = dependencies.dependsOn
+	}
+	if len(dependencies.usedBy) > 0 {
+		attributes[attributeServiceUsedBy] = dependencies.usedBy
+	}
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/extension-stackstate/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func k8sComponent(id int, clusterName string, namespace string, name string) Component {
+	return Component{
+		Id:   id,
+		Name: name,
+		Properties: Properties{
+			ClusterNameIdentifier: "urn:cluster:/kubernetes:" + clusterName,
+			NamespaceIdentifier:   fmt.Sprintf("urn:kubernetes:/%s:namespace/%s", clusterName, namespace),
+		},
+	}
+}
+
+func TestDependenciesOf(t *testing.T) {
+	dependencies := dependenciesOf(ViewSnapshotResponse{
+		Components: []Component{
+			k8sComponent(1, "prod", "shop", "checkout"),
+			k8sComponent(2, "prod", "shop", "orders"),
+			k8sComponent(3, "prod", "shop", "postgres"),
+			k8sComponent(4, "prod", "shop", "cache"),
+		},
+		Relations: []Relation{
+			{Source: 1, Target: 3, DependencyDirection: "ONE_WAY"},
+			{Source: 2, Target: 3, DependencyDirection: "ONE_WAY"},
+			{Source: 1, Target: 2},
+			{Source: 2, Target: 4, DependencyDirection: "BOTH"},
+			{Source: 1, Target: 4, DependencyDirection: "NONE"},
+			{Source: 1, Target: 99, DependencyDirection: "ONE_WAY"},
+			{Source: 3, Target: 3, DependencyDirection: "ONE_WAY"},
+		},
+	})
+
+	assert.Equal(t, []string{"prod/shop/orders", "prod/shop/postgres"}, dependencies[1].dependsOn)
+	assert.Empty(t, dependencies[1].usedBy)
+	assert.Equal(t, []string{"prod/shop/cache", "prod/shop/postgres"}, dependencies[2].dependsOn)
+	assert.Equal(t, []string{"prod/shop/cache", "prod/shop/checkout"}, dependencies[2].usedBy)
+	assert.Empty(t, dependencies[3].dependsOn)
+	assert.Equal(t, []string{"prod/shop/checkout", "prod/shop/orders"}, dependencies[3].usedBy)
+	assert.Equal(t, []string{"prod/shop/orders"}, dependencies[4].dependsOn)
+	assert.Equal(t, []string{"prod/shop/orders"}, dependencies[4].usedBy)
+}
+
+func TestDependenciesOfSameNamedServices(t *testing.T) {
+	dependencies := dependenciesOf(ViewSnapshotResponse{
+		Components: []Component{
+			k8sComponent(1, "prod", "shop", "checkout"),
+			k8sComponent(2, "prod", "shop", "postgres"),
+			k8sComponent(3, "prod", "billing", "invoices"),
+			k8sComponent(4, "prod", "billing", "postgres"),
+		},
+		Relations: []Relation{
+			{Source: 1, Target: 2, DependencyDirection: "ONE_WAY"},
+			{Source: 3, Target: 4, DependencyDirection: "ONE_WAY"},
+		},
+	})
+
+	assert.Equal(t, []string{"prod/shop/postgres"}, dependencies[1].dependsOn)
+	assert.Equal(t, []string{"prod/billing/postgres"}, dependencies[3].dependsOn)
+	assert.Equal(t, []string{"prod/shop/checkout"}, dependencies[2].usedBy)
+	assert.Equal(t, []string{"prod/billing/invoices"}, dependencies[4].usedBy)
+}
+
+func TestDependencyKeyWithoutKubernetesProperties(t *testing.T) {
+	assert.Equal(t, "urn:stackpack:aws:lambda/orders", dependencyKey(Component{Id: 7, Name: "orders", Identifiers: []string{"urn:stackpack:aws:lambda/orders"}}))
+	assert.Equal(t, "7", dependencyKey(Component{Id: 7, Name: "orders"}))
+}
+
+func TestServiceDiscoveryAddsDependencyAttributes(t *testing.T) {
+	t.Cleanup(func() {
+		config.Config.DiscoveryServiceRelations = false
+	})
+	config.Config.DiscoveryServiceRelations = true
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var request struct {
+			Metadata struct {
+				ShowIndirectRelations bool `json:"showIndirectRelations"`
+			} `json:"metadata"`
+		}
+		require.NoError(t, json.Unmarshal(body, &request))
+		assert.True(t, request.Metadata.ShowIndirectRelations)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"viewSnapshotResponse":{
+			"components":[
+				{"id":1,"name":"checkout","properties":{"clusterNameIdentifier":"urn:cluster:/kubernetes:prod","namespaceIdentifier":"urn:kubernetes:/prod:namespace/shop"}},
+				{"id":2,"name":"postgres","properties":{"clusterNameIdentifier":"urn:cluster:/kubernetes:prod","namespaceIdentifier":"urn:kubernetes:/prod:namespace/shop"}}
+			],
+			"relations":[{"source":1,"target":2,"dependencyDirection":"ONE_WAY"}]
+		}}`))
+	}))
+	t.Cleanup(srv.Close)
+	client := &StackStateHttpClient{Client: resty.New().SetBaseURL(srv.URL)}
+
+	targets, err := getAllServices(context.Background(), client)
+
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	assert.Equal(t, []string{"prod/shop/postgres"}, targets[0].Attributes["stackstate.service.depends-on"])
+	assert.NotContains(t, targets[0].Attributes, "stackstate.service.used-by")
+	assert.Equal(t, []string{"prod/shop/checkout"}, targets[1].Attributes["stackstate.service.used-by"])
+	assert.NotContains(t, targets[1].Attributes, "stackstate.service.depends-on")
+}
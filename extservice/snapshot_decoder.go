@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxErrorBodySize limits how much of an unexpected response is kept for logging.
+const maxErrorBodySize = 64 * 1024
+
+// decodeSnapshotResponse decodes the components of a snapshot response one by one while streaming the response body.
+// Unlike unmarshalling the whole response, the raw body is never held in memory, only the fields modeled by Component
+// are kept. BenchmarkSnapshotQuery compares the peak heap with the buffered response.
+func decodeSnapshotResponse(body io.Reader) (ViewSnapshotResponseWrapper, error) {
+	var result ViewSnapshotResponseWrapper
+	decoder := json.NewDecoder(body)
+	err := decodeObject(decoder, func(key string) error {
+		if key != "viewSnapshotResponse" {
+			return skipValue(decoder)
+		}
+		return decodeObject(decoder, func(key string) error {
+			if key == "metadata" {
+				return decoder.Decode(&result.ViewSnapshotResponse.Metadata)
+			}
+			if key == "relations" {
+				return decoder.Decode(&result.ViewSnapshotResponse.Relations)
+			}
+			if key != "components" {
+				return skipValue(decoder)
+			}
+			components := &result.ViewSnapshotResponse.Components
+			return decodeArray(decoder, func() error {
+				*components = append(*components, Component{})
+				return decoder.Decode(&(*components)[len(*components)-1])
+			})
+		})
+	})
+	result.ViewSnapshotResponse.resolveNames()
+	return result, err
+}
+
+// decodeObject calls field for every key of the next JSON object, field has to consume the value. null is accepted
+// as an empty object.
+func decodeObject(decoder *json.Decoder, field func(key string) error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('{') {
+		return fmt.Errorf("expected JSON object but found %v", token)
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if err := field(key.(string)); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
+
+// decodeArray calls element for every element of the next JSON array, element has to consume the value. null is
+// accepted as an empty array.
+func decodeArray(decoder *json.Decoder, element func() error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("expected JSON array but found %v", token)
+	}
+	for decoder.More() {
+		if err := element(); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
+
+// skipValue consumes the next JSON value token by token without keeping it.
+func skipValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeSnapshotResponse(t *testing.T) {
+	body := `{
+		"_type": "ViewSnapshotResponse",
+		"viewSnapshotResponse": {
+			"_type": "ViewSnapshot",
+			"metadata": {"groupingEnabled": false, "nested": [[1, 2], {"a": null}]},
+			"components": [
+				{
+					"id": 1,
+					"name": "checkout",
+					"type": 42,
+					"layer": 7,
+					"state": {"healthState": "CLEAR", "propagatedHealthState": "DEVIATING"},
+					"properties": {"clusterNameIdentifier": "urn:cluster:/kubernetes:prod", "namespaceIdentifier": "urn:kubernetes:/prod:namespace/shop", "other": "x"},
+					"identifiers": ["urn:service:/prod:shop:checkout"],
+					"synced": [{"extTopologyElement": {"data": {"huge": "payload"}}}]
+				},
+				{"id": 2, "name": "orders"}
+			],
+			"relations": [{"id": 3}]
+		}
+	}`
+
+	result, err := decodeSnapshotResponse(strings.NewReader(body))
+
+	require.NoError(t, err)
+	var expected ViewSnapshotResponseWrapper
+	require.NoError(t, json.Unmarshal([]byte(body), &expected))
+	assert.Equal(t, expected, result)
+	assert.Len(t, result.ViewSnapshotResponse.Components, 2)
+}
+
+func TestDecodeSnapshotResponse_Empty(t *testing.T) {
+	for _, body := range []string{`{}`, `{"viewSnapshotResponse": null}`, `{"viewSnapshotResponse": {"components": null}}`} {
+		result, err := decodeSnapshotResponse(strings.NewReader(body))
+		require.NoError(t, err, body)
+		assert.Empty(t, result.ViewSnapshotResponse.Components, body)
+	}
+}
+
+func TestDecodeSnapshotResponse_Invalid(t *testing.T) {
+	for _, body := range []string{``, `[]`, `{"viewSnapshotResponse": {"components": {}}}`, `{"viewSnapshotResponse": {"components": [{"id": "x"}]}}`} {
+		_, err := decodeSnapshotResponse(strings.NewReader(body))
+		assert.Error(t, err, body)
+	}
+}
+
+// snapshotFixture renders a snapshot response with the given number of components, including fields StackState
+// returns but the extension doesn't model.
+func snapshotFixture(components int) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(`{"_type":"ViewSnapshotResponse","viewSnapshotResponse":{"_type":"ViewSnapshot","components":[`)
+	for i := 0; i < components; i++ {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		_, _ = fmt.Fprintf(&buffer, `{"_type":"Component","id":%[1]d,"name":"service-%[1]d","description":"A service discovered from Kubernetes with a lengthy description","type":1,"layer":2,"domain":3,"environments":[4],"labels":[{"_type":"Label","id":%[1]d,"name":"cluster-name:prod"},{"_type":"Label","id":%[1]d,"name":"namespace:ns-%[2]d"}],"state":{"_type":"ComponentState","id":%[1]d,"healthState":"CLEAR","propagatedHealthState":"CLEAR"},"properties":{"clusterNameIdentifier":"urn:cluster:/kubernetes:prod","namespaceIdentifier":"urn:kubernetes:/prod:namespace/ns-%[2]d","uid":"0f8fad5b-d9cb-469f-a165-70867728950e","creationTimestamp":"2024-01-01T00:00:00Z"},"identifiers":["urn:kubernetes:/prod:ns-%[2]d:service/service-%[1]d"],"synced":[{"_type":"Synced","sync":%[1]d,"extTopologyElement":{"externalId":"urn:kubernetes:/prod:ns-%[2]d:service/service-%[1]d","data":{"metadata":{"annotations":{"a":"b"}}}}}]}`, i, i%100)
+	}
+	buffer.WriteString(`]}}`)
+	return buffer.Bytes()
+}
+
+// BenchmarkSnapshotQuery compares fetching a snapshot of 50k components with the streaming decoder to letting resty
+// buffer and unmarshal the whole response, which is how snapshots used to be fetched. Besides the allocations, it
+// reports the peak heap in use while the query runs.
+func BenchmarkSnapshotQuery(b *testing.B) {
+	fixture := snapshotFixture(50_000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(fixture)
+	}))
+	b.Cleanup(srv.Close)
+	client := &StackStateHttpClient{Client: resty.New().SetBaseURL(srv.URL)}
+
+	b.Run("streaming", func(b *testing.B) {
+		benchmarkPeakHeap(b, func() int {
+			_, result, err := client.executeSnapshotQuery(context.Background(), "type = \"service\"")
+			if err != nil {
+				b.Fatal(err)
+			}
+			return len(result.ViewSnapshotResponse.Components)
+		})
+	})
+
+	b.Run("buffered", func(b *testing.B) {
+		benchmarkPeakHeap(b, func() int {
+			var result ViewSnapshotResponseWrapper
+			_, err := client.Client.R().SetBody([]byte(`{}`)).SetResult(&result).Post("/snapshot")
+			if err != nil {
+				b.Fatal(err)
+			}
+			return len(result.ViewSnapshotResponse.Components)
+		})
+	})
+}
+
+// benchmarkPeakHeap runs query and reports the highest heap in use above the heap before the run, sampled every
+// millisecond.
+func benchmarkPeakHeap(b *testing.B, query func() int) {
+	b.ReportAllocs()
+	var peak uint64
+	for b.Loop() {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		baseline := stats.HeapInuse
+		done := make(chan struct{})
+		var sampler sync.WaitGroup
+		sampler.Go(func() {
+			ticker := time.NewTicker(time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					var stats runtime.MemStats
+					runtime.ReadMemStats(&stats)
+					if stats.HeapInuse > baseline {
+						peak = max(peak, stats.HeapInuse-baseline)
+					}
+				}
+			}
+		})
+		if components := query(); components != 50_000 {
+			b.Fatalf("expected 50000 components, got %d", components)
+		}
+		close(done)
+		sampler.Wait()
+	}
+	b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MiB")
+}
+
+func TestDecodeSnapshotResponse_ResolvesNames(t *testing.T) {
+	body := `{"viewSnapshotResponse": {
+		"components": [{"id": 1, "name": "checkout", "type": 10, "layer": 20, "domain": 30, "labels": [{"name": "team:shop"}], "properties": {"clusterNameIdentifier": "urn:cluster:/kubernetes:prod", "replicas": 3, "tier": "frontend", "selector": {"app": "checkout"}}}],
+		"metadata": {"componentTypes": [{"id": 10, "name": "service"}], "layers": [{"id": 20, "name": "Services"}], "domains": [{"id": 30, "name": "Kubernetes"}]}
+	}}`
+
+	result, err := decodeSnapshotResponse(strings.NewReader(body))
+
+	require.NoError(t, err)
+	component := result.ViewSnapshotResponse.Components[0]
+	assert.Equal(t, "service", component.TypeName)
+	assert.Equal(t, "Services", component.LayerName)
+	assert.Equal(t, "Kubernetes", component.DomainName)
+	assert.Equal(t, []Label{{Name: "team:shop"}}, component.Labels)
+	assert.Equal(t, map[string]string{"tier": "frontend"}, component.Properties.Values)
+	assert.Equal(t, "urn:cluster:/kubernetes:prod", component.Properties.ClusterNameIdentifier)
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// snapshotPollerWindow is how long the poller collects component ids before it queries them in one go.
+	snapshotPollerWindow = 250 * time.Millisecond
+	// snapshotPollerMaxBatchSize limits the number of ids per query to keep the STQL query reasonably small.
+	snapshotPollerMaxBatchSize = 500
+)
+
+// snapshotPoller coalesces the GetServiceSnapshot calls of concurrently running status checks. All calls arriving
+// within the same window share one `id in (...)` snapshot query, so the number of queries sent to StackState no
+// longer grows with the number of checked components. GetRelatedComponents calls of the blast radius checks for the
+// same component arriving within the window share one query as well.
+type snapshotPoller struct {
+	api     snapshotPollerApi
+	window  time.Duration
+	mu      sync.Mutex
+	batch   *snapshotBatch
+	related map[relatedComponentsQuery]*snapshotBatch
+}
+
+type snapshotPollerApi interface {
+	QuerySnapshotsApi
+	GetRelatedComponentsApi
+}
+
+type relatedComponentsQuery struct {
+	query     string
+	connected bool
+}
+
+type snapshotBatch struct {
+	ids      []string
+	done     chan struct{}
+	response *resty.Response
+	result   ViewSnapshotResponseWrapper
+	err      error
+}
+
+// Make sure the poller can be used in place of the client by the status and blast radius checks
+var (
+	_ GetSnapshotApi          = (*snapshotPoller)(nil)
+	_ GetRelatedComponentsApi = (*snapshotPoller)(nil)
+)
+
+var sharedSnapshotPoller = sync.OnceValue(func() *snapshotPoller {
+	return newSnapshotPoller(Client, snapshotPollerWindow)
+})
+
+func newSnapshotPoller(api snapshotPollerApi, window time.Duration) *snapshotPoller {
+	return &snapshotPoller{
+		api:     api,
+		window:  window,
+		related: make(map[relatedComponentsQuery]*snapshotBatch),
+	}
+}
+
+func (p *snapshotPoller) GetServiceSnapshot(ctx context.Context, serviceId string) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	batch := p.join(serviceId)
+
+	if err := batch.wait(ctx); err != nil {
+		return nil, ViewSnapshotResponseWrapper{}, err
+	}
+	if batch.err != nil {
+		return batch.response, ViewSnapshotResponseWrapper{}, batch.err
+	}
+
+	var result ViewSnapshotResponseWrapper
+	for _, component := range batch.result.ViewSnapshotResponse.Components {
+		if strconv.Itoa(component.Id) == serviceId {
+			result.ViewSnapshotResponse.Components = append(result.ViewSnapshotResponse.Components, component)
+		}
+	}
+	return batch.response, result, nil
+}
+
+func (p *snapshotPoller) QuerySnapshots(ctx context.Context, query string) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	return p.api.QuerySnapshots(ctx, query)
+}
+
+// GetRelatedComponents shares the result with all callers asking for the same related components within the window,
+// so the callers must not modify it.
+func (p *snapshotPoller) GetRelatedComponents(ctx context.Context, query string, connected bool) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	key := relatedComponentsQuery{query: query, connected: connected}
+	p.mu.Lock()
+	batch, ok := p.related[key]
+	if !ok {
+		batch = &snapshotBatch{done: make(chan struct{})}
+		p.related[key] = batch
+		time.AfterFunc(p.window, func() {
+			p.executeRelated(key, batch)
+		})
+	}
+	p.mu.Unlock()
+
+	if err := batch.wait(ctx); err != nil {
+		return nil, ViewSnapshotResponseWrapper{}, err
+	}
+	return batch.response, batch.result, batch.err
+}
+
+// join adds the id to the currently collecting batch, starting a new one if there is none or it is full.
+func (p *snapshotPoller) join(serviceId string) *snapshotBatch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.batch == nil || len(p.batch.ids) >= snapshotPollerMaxBatchSize {
+		batch := &snapshotBatch{done: make(chan struct{})}
+		p.batch = batch
+		time.AfterFunc(p.window, func() {
+			p.execute(batch)
+		})
+	}
+	if !slices.Contains(p.batch.ids, serviceId) {
+		p.batch.ids = append(p.batch.ids, serviceId)
+	}
+	return p.batch
+}
+
+func (p *snapshotPoller) execute(batch *snapshotBatch) {
+	p.mu.Lock()
+	if p.batch == batch {
+		p.batch = nil
+	}
+	ids := make([]string, 0, len(batch.ids))
+	for _, id := range batch.ids {
+		ids = append(ids, stqlString(id))
+	}
+	p.mu.Unlock()
+
+	// The query serves all callers of the batch, so it must not be cancelled together with one of their contexts.
+	batch.response, batch.result, batch.err = p.api.QuerySnapshots(context.Background(), fmt.Sprintf("(id in (%s))", strings.Join(ids, ", ")))
+	close(batch.done)
+}
+
+func (p *snapshotPoller) executeRelated(key relatedComponentsQuery, batch *snapshotBatch) {
+	p.mu.Lock()
+	delete(p.related, key)
+	p.mu.Unlock()
+
+	// As above, the query serves all callers and doesn't use their contexts.
+	batch.response, batch.result, batch.err = p.api.GetRelatedComponents(context.Background(), key.query, key.connected)
+	close(batch.done)
+}
+
+// wait blocks until the batch was executed or the context is done.
+func (b *snapshotBatch) wait(ctx context.Context) error {
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type snapshotPollerApiMock struct {
+	mock.Mock
+}
+
+func (m *snapshotPollerApiMock) QuerySnapshots(ctx context.Context, query string) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(*resty.Response), args.Get(1).(ViewSnapshotResponseWrapper), args.Error(2)
+}
+
+func (m *snapshotPollerApiMock) GetRelatedComponents(ctx context.Context, query string, connected bool) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	args := m.Called(ctx, query, connected)
+	return args.Get(0).(*resty.Response), args.Get(1).(ViewSnapshotResponseWrapper), args.Error(2)
+}
+
+func TestSnapshotPoller(t *testing.T) {
+	t.Run("coalesces concurrent calls into one query", func(t *testing.T) {
+		mockedApi := new(snapshotPollerApiMock)
+		mockedApi.On("QuerySnapshots", mock.Anything, mock.MatchedBy(func(query string) bool {
+			return query == `(id in ("1", "2"))` || query == `(id in ("2", "1"))`
+		})).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{
+			ViewSnapshotResponse: ViewSnapshotResponse{
+				Components: []Component{
+					{Id: 1, Name: "service1", State: State{HealthState: "CLEAR"}},
+					{Id: 2, Name: "service2", State: State{HealthState: "CRITICAL"}},
+				},
+			},
+		}, nil).Once()
+		poller := newSnapshotPoller(mockedApi, 50*time.Millisecond)
+
+		// The goroutines only collect the results, the test goroutine asserts on them.
+		ids := []string{"1", "2", "1"}
+		responses := make([]*resty.Response, len(ids))
+		results := make([]ViewSnapshotResponseWrapper, len(ids))
+		errs := make([]error, len(ids))
+		var wg sync.WaitGroup
+		for i, id := range ids {
+			wg.Go(func() {
+				responses[i], results[i], errs[i] = poller.GetServiceSnapshot(context.TODO(), id)
+			})
+		}
+		wg.Wait()
+
+		mockedApi.AssertExpectations(t)
+		for i := range ids {
+			require.NoError(t, errs[i])
+			require.True(t, responses[i].IsSuccess())
+			require.Len(t, results[i].ViewSnapshotResponse.Components, 1)
+		}
+		require.Equal(t, "service1", results[0].ViewSnapshotResponse.Components[0].Name)
+		require.Equal(t, "CRITICAL", results[1].ViewSnapshotResponse.Components[0].State.HealthState)
+		require.Equal(t, "service1", results[2].ViewSnapshotResponse.Components[0].Name)
+	})
+
+	t.Run("returns no components for unknown ids", func(t *testing.T) {
+		mockedApi := new(snapshotPollerApiMock)
+		mockedApi.On("QuerySnapshots", mock.Anything, `(id in ("3"))`).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+		poller := newSnapshotPoller(mockedApi, time.Millisecond)
+
+		_, result, err := poller.GetServiceSnapshot(context.TODO(), "3")
+
+		require.NoError(t, err)
+		require.Empty(t, result.ViewSnapshotResponse.Components)
+	})
+
+	t.Run("passes errors to all callers", func(t *testing.T) {
+		mockedApi := new(snapshotPollerApiMock)
+		mockedApi.On("QuerySnapshots", mock.Anything, mock.Anything).Return(apiResponseWithStatus(500), ViewSnapshotResponseWrapper{}, errors.New("boom"))
+		poller := newSnapshotPoller(mockedApi, time.Millisecond)
+
+		_, _, err := poller.GetServiceSnapshot(context.TODO(), "1")
+
+		require.EqualError(t, err, "boom")
+	})
+
+	t.Run("coalesces concurrent related components calls of the same component", func(t *testing.T) {
+		mockedApi := new(snapshotPollerApiMock)
+		mockedApi.On("GetRelatedComponents", mock.Anything, `(id = "1")`, false).Return(apiResponseWithStatus(200), relatedComponents(map[int]string{1: "CLEAR", 2: "CRITICAL"}), nil).Once()
+		mockedApi.On("GetRelatedComponents", mock.Anything, `(id = "1")`, true).Return(apiResponseWithStatus(200), relatedComponents(map[int]string{1: "CLEAR", 2: "CLEAR"}), nil).Once()
+		poller := newSnapshotPoller(mockedApi, 50*time.Millisecond)
+
+		connected := []bool{false, false, true}
+		results := make([]ViewSnapshotResponseWrapper, len(connected))
+		errs := make([]error, len(connected))
+		var wg sync.WaitGroup
+		for i := range connected {
+			wg.Go(func() {
+				_, results[i], errs[i] = poller.GetRelatedComponents(context.TODO(), `(id = "1")`, connected[i])
+			})
+		}
+		wg.Wait()
+
+		mockedApi.AssertExpectations(t)
+		for i, expected := range []string{"CRITICAL", "CRITICAL", "CLEAR"} {
+			require.NoError(t, errs[i])
+			require.Equal(t, expected, results[i].ViewSnapshotResponse.Components[1].State.HealthState)
+		}
+	})
+
+	t.Run("stops waiting when the context is done", func(t *testing.T) {
+		mockedApi := new(snapshotPollerApiMock)
+		mockedApi.On("QuerySnapshots", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+		poller := newSnapshotPoller(mockedApi, time.Hour)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, _, err := poller.GetServiceSnapshot(ctx, "1")
+
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
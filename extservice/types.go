@@ -1,22 +1,131 @@
 package extservice
 
+import "encoding/json"
+
 type ViewSnapshotResponseWrapper struct {
 	ViewSnapshotResponse ViewSnapshotResponse `json:"viewSnapshotResponse"`
 }
 type ViewSnapshotResponse struct {
-	Components []Component `json:"components"`
+	Components []Component          `json:"components"`
+	Relations  []Relation           `json:"relations"`
+	Metadata   ViewSnapshotMetadata `json:"metadata"`
+}
+
+// ViewSnapshotMetadata holds the names of the component types, layers and domains the components refer to by id.
+type ViewSnapshotMetadata struct {
+	ComponentTypes []NamedElement `json:"componentTypes"`
+	Layers         []NamedElement `json:"layers"`
+	Domains        []NamedElement `json:"domains"`
+}
+type NamedElement struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
 }
 type Component struct {
 	Id          int        `json:"id"`
 	Name        string     `json:"name"`
+	Type        int        `json:"type"`
+	Layer       int        `json:"layer"`
+	Domain      int        `json:"domain"`
+	Labels      []Label    `json:"labels"`
 	State       State      `json:"state"`
 	Properties  Properties `json:"properties"`
 	Identifiers []string   `json:"identifiers"`
+	// TypeName, LayerName and DomainName are resolved from the ViewSnapshotMetadata.
+	TypeName   string `json:"-"`
+	LayerName  string `json:"-"`
+	DomainName string `json:"-"`
+}
+
+// Relation connects the source and target component ids. With the ONE_WAY dependency direction the source depends on
+// the target, with BOTH they depend on each other and with NONE there is no dependency.
+type Relation struct {
+	Source              int    `json:"source"`
+	Target              int    `json:"target"`
+	DependencyDirection string `json:"dependencyDirection"`
+}
+type Label struct {
+	Name string `json:"name"`
 }
 type State struct {
-	HealthState string `json:"healthState"`
+	HealthState           string `json:"healthState"`
+	PropagatedHealthState string `json:"propagatedHealthState"`
 }
 type Properties struct {
 	NamespaceIdentifier   string `json:"namespaceIdentifier"`
 	ClusterNameIdentifier string `json:"clusterNameIdentifier"`
+	// Values holds the other properties with a string value.
+	Values map[string]string `json:"-"`
+}
+
+func (p *Properties) UnmarshalJSON(data []byte) error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	p.Values = make(map[string]string, len(values))
+	for key, value := range values {
+		// Only string values are kept, everything else is skipped without decoding it.
+		if len(value) == 0 || value[0] != '"' {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return err
+		}
+		switch key {
+		case "namespaceIdentifier":
+			p.NamespaceIdentifier = s
+		case "clusterNameIdentifier":
+			p.ClusterNameIdentifier = s
+		default:
+			p.Values[key] = s
+		}
+	}
+	return nil
+}
+
+// resolveNames fills the type, layer and domain names of the components from the metadata.
+func (r *ViewSnapshotResponse) resolveNames() {
+	names := func(elements []NamedElement) map[int]string {
+		result := make(map[int]string, len(elements))
+		for _, element := range elements {
+			result[element.Id] = element.Name
+		}
+		return result
+	}
+	types, layers, domains := names(r.Metadata.ComponentTypes), names(r.Metadata.Layers), names(r.Metadata.Domains)
+	for i := range r.Components {
+		r.Components[i].TypeName = types[r.Components[i].Type]
+		r.Components[i].LayerName = layers[r.Components[i].Layer]
+		r.Components[i].DomainName = domains[r.Components[i].Domain]
+	}
+}
+
+type MonitorsResponse struct {
+	Monitors []Monitor `json:"monitors"`
+}
+type Monitor struct {
+	Id         int      `json:"id"`
+	Name       string   `json:"name"`
+	Identifier string   `json:"identifier"`
+	Status     string   `json:"status"`
+	Tags       []string `json:"tags"`
+}
+type MonitorStatusResponse struct {
+	MonitorHealthStateStateCounts HealthStateCounts `json:"monitorHealthStateStateCounts"`
+}
+type HealthStateCounts struct {
+	ClearCount     int `json:"clearCount"`
+	DeviatingCount int `json:"deviatingCount"`
+	CriticalCount  int `json:"criticalCount"`
+}
+
+type HealthHistoryResponse struct {
+	HealthStateChanges []HealthStateChange `json:"healthStateChanges"`
+}
+type HealthStateChange struct {
+	Timestamp      int64  `json:"timestamp"`
+	OldHealthState string `json:"oldHealthState"`
+	NewHealthState string `json:"newHealthState"`
 }
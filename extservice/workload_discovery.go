@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_api"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_commons"
+	"github.com/steadybit/discovery-kit/go/discovery_kit_sdk"
+	"github.com/steadybit/extension-kit/extbuild"
+	"github.com/steadybit/extension-stackstate/config"
+)
+
+// workloadDiscovery discovers the StackState components of a Kubernetes workload type (deployments, statefulsets,
+// daemonsets, pods) as their own target type, so a status check can be aimed at the workload an attack targets.
+type workloadDiscovery struct {
+	kind      componentKind
+	lastKnown lastKnownTargets
+}
+
+var (
+	_ discovery_kit_sdk.TargetDescriber          = (*workloadDiscovery)(nil)
+	_ discovery_kit_sdk.AttributeDescriber       = (*workloadDiscovery)(nil)
+	_ discovery_kit_sdk.EnrichmentRulesDescriber = (*workloadDiscovery)(nil)
+)
+
+type GetComponentSnapshotsApi interface {
+	GetComponentSnapshots(ctx context.Context, componentType string) (*resty.Response, ViewSnapshotResponseWrapper, error)
+}
+
+func NewWorkloadDiscoveries() []discovery_kit_sdk.TargetDiscovery {
+	discoveries := make([]discovery_kit_sdk.TargetDiscovery, 0, len(workloadKinds))
+	for _, kind := range workloadKinds {
+		discoveries = append(discoveries, discovery_kit_sdk.NewCachedTargetDiscovery(&workloadDiscovery{kind: kind},
+			discovery_kit_sdk.WithRefreshTargetsNow(),
+			discovery_kit_sdk.WithRefreshTargetsInterval(context.Background(), config.Config.DiscoveryInterval),
+		))
+	}
+	return discoveries
+}
+
+func (d *workloadDiscovery) Describe() discovery_kit_api.DiscoveryDescription {
+	return discovery_kit_api.DiscoveryDescription{
+		Id: d.kind.targetType,
+		Discover: discovery_kit_api.DescribingEndpointReferenceWithCallInterval{
+			CallInterval: new(config.Config.DiscoveryInterval.String()),
+		},
+	}
+}
+
+func (d *workloadDiscovery) DescribeTarget() discovery_kit_api.TargetDescription {
+	return discovery_kit_api.TargetDescription{
+		Id: d.kind.targetType,
+		Label: discovery_kit_api.PluralLabel{
+			One:   fmt.Sprintf("StackState %s", d.kind.label.One),
+			Other: fmt.Sprintf("StackState %s", d.kind.label.Other),
+		},
+		Category: new("monitoring"),
+		Version:  extbuild.GetSemverVersionStringOrUnknown(),
+		Icon:     new(serviceIcon),
+		Table: discovery_kit_api.Table{
+			Columns: []discovery_kit_api.Column{
+				{Attribute: d.kind.nameAttribute},
+				{Attribute: attributeK8Namespace},
+				{Attribute: attributeK8ClusterName},
+			},
+			OrderBy: []discovery_kit_api.OrderBy{
+				{
+					Attribute: d.kind.nameAttribute,
+					Direction: "ASC",
+				},
+			},
+		},
+	}
+}
+
+// DescribeAttributes only describes the workload name, the cluster and namespace attributes are already described
+// by the service discovery.
+func (d *workloadDiscovery) DescribeAttributes() []discovery_kit_api.AttributeDescription {
+	return []discovery_kit_api.AttributeDescription{
+		{
+			Attribute: d.kind.nameAttribute,
+			Label:     d.kind.label,
+		},
+	}
+}
+
+func (d *workloadDiscovery) DescribeEnrichmentRules() []discovery_kit_api.TargetEnrichmentRule {
+	return enrichmentRules(d.kind)
+}
+
+func (d *workloadDiscovery) DiscoverTargets(ctx context.Context) ([]discovery_kit_api.Target, error) {
+	return d.lastKnown.keep(getAllWorkloads(ctx, Client, d.kind))
+}
+
+func getAllWorkloads(ctx context.Context, api GetComponentSnapshotsApi, kind componentKind) ([]discovery_kit_api.Target, error) {
+	result := make([]discovery_kit_api.Target, 0, 500)
+	res, stackStateResponse, err := api.GetComponentSnapshots(ctx, kind.componentType)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve %s states from StackState: %w", kind.componentType, err)
+	}
+
+	if res.StatusCode() != 200 {
+		return nil, fmt.Errorf("StackState API responded with unexpected status code %d while retrieving %s states", res.StatusCode(), kind.componentType)
+	}
+
+	for _, component := range stackStateResponse.ViewSnapshotResponse.Components {
+		if inDiscoveryScope(component) {
+			result = append(result, toWorkload(component, kind))
+		}
+	}
+	return discovery_kit_commons.ApplyAttributeExcludes(result, kind.attributeExcludes()), nil
+}
+
+func toWorkload(workload Component, kind componentKind) discovery_kit_api.Target {
+	clusterName, namespace := clusterAndNamespace(workload)
+	attributes := map[string][]string{
+		attributeComponentId:   {strconv.Itoa(workload.Id)},
+		kind.nameAttribute:     {workload.Name},
+		attributeK8Namespace:   {namespace},
+		attributeK8ClusterName: {clusterName},
+	}
+	if urn := componentUrn(workload); urn != "" {
+		attributes[attributeComponentUrn] = []string{urn}
+	}
+	addComponentAttributes(attributes, workload)
+	return discovery_kit_api.Target{
+		Id:         targetId(workload),
+		Label:      workload.Name,
+		TargetType: kind.targetType,
+		Attributes: attributes,
+	}
+}
+
+// replicaSetPodName matches the pods of a ReplicaSet, named after the ReplicaSet (deployment name and
+// pod-template-hash) and a random suffix. Both the hash and the suffix use Kubernetes' alphabet without vowels.
+var replicaSetPodName = regexp.MustCompile(`^(.+-[bcdfghjklmnpqrstvwxz2456789]{6,10}-)[bcdfghjklmnpqrstvwxz2456789]{5}$`)
+
+// podOwnerPrefix strips the generated suffix from the name of a ReplicaSet pod, e.g. "checkout-7d9f8b6c4-x2k9p"
+// becomes "checkout-7d9f8b6c4-", the prefix of all pods of the ReplicaSet. Other pods have no owner prefix, e.g. the
+// prefix "postgres-" of the StatefulSet pod "postgres-0" would also match the pods of "postgres-exporter".
+func podOwnerPrefix(name string) string {
+	match := replicaSetPodName.FindStringSubmatch(name)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
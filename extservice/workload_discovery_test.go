@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: 2024 Steadybit GmbH
+
+package extservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/steadybit/action-kit/go/action_kit_api/v2"
+	"github.com/steadybit/extension-kit/extutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type getComponentSnapshotsApiMock struct {
+	mock.Mock
+}
+
+func (m *getComponentSnapshotsApiMock) GetComponentSnapshots(ctx context.Context, componentType string) (*resty.Response, ViewSnapshotResponseWrapper, error) {
+	args := m.Called(ctx, componentType)
+	return args.Get(0).(*resty.Response), args.Get(1).(ViewSnapshotResponseWrapper), args.Error(2)
+}
+
+func TestGetAllWorkloads(t *testing.T) {
+	mockedApi := new(getComponentSnapshotsApiMock)
+	mockedApi.On("GetComponentSnapshots", mock.Anything, "deployment").Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{
+		ViewSnapshotResponse: ViewSnapshotResponse{
+			Components: []Component{
+				{
+					Id:   42,
+					Name: "checkout",
+					Properties: Properties{
+						ClusterNameIdentifier: "urn:cluster:/kubernetes:prod",
+						NamespaceIdentifier:   "urn:kubernetes:/prod:namespace/shop",
+					},
+				},
+			},
+		},
+	}, nil)
+
+	targets, err := getAllWorkloads(context.Background(), mockedApi, deploymentKind)
+	require.NoError(t, err)
+
+	require.Len(t, targets, 1)
+	assert.Equal(t, "42", targets[0].Id)
+	assert.Equal(t, deploymentTargetType, targets[0].TargetType)
+	assert.Equal(t, map[string][]string{
+		"stackstate.component.id": {"42"},
+		"k8s.deployment":          {"checkout"},
+		"k8s.namespace":           {"shop"},
+		"k8s.cluster-name":        {"prod"},
+	}, targets[0].Attributes)
+}
+
+func TestGetAllWorkloads_UnexpectedStatus(t *testing.T) {
+	mockedApi := new(getComponentSnapshotsApiMock)
+	mockedApi.On("GetComponentSnapshots", mock.Anything, "daemonset").Return(apiResponseWithStatus(500), ViewSnapshotResponseWrapper{}, nil)
+
+	targets, err := getAllWorkloads(context.Background(), mockedApi, daemonSetKind)
+
+	assert.Empty(t, targets)
+	assert.EqualError(t, err, "StackState API responded with unexpected status code 500 while retrieving daemonset states")
+}
+
+func TestWorkloadStatusCheck(t *testing.T) {
+	workloadAction := &ServiceStatusCheckAction{kind: statefulSetKind}
+	assert.Equal(t, "com.steadybit.extension_stackstate.statefulset.check", workloadAction.Describe().Id)
+
+	request := extutil.JsonMangle(action_kit_api.PrepareActionRequestBody{
+		Config: map[string]any{
+			"duration":       1000 * 60,
+			"expectedStatus": "CLEAR",
+		},
+		Target: &action_kit_api.Target{
+			Attributes: map[string][]string{
+				"stackstate.component.id": {"7"},
+				"k8s.statefulset":         {"postgres"},
+				"k8s.cluster-name":        {"prod"},
+			},
+		},
+	})
+	state := workloadAction.NewEmptyState()
+	_, err := workloadAction.Prepare(context.TODO(), &state, request)
+	require.NoError(t, err)
+	assert.Equal(t, statefulSetTargetType, state.TargetType)
+	assert.Equal(t, "7", state.ServiceId)
+	assert.Equal(t, "postgres", state.ServiceName)
+
+	mockedApi := new(getSnapshotApiMock)
+	mockedApi.On("GetServiceSnapshot", mock.Anything, "7").Return(apiResponseWithStatus(200), serviceResponseWithState("CRITICAL"), nil)
+	status, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+	require.NoError(t, err)
+	require.NotNil(t, status.Error)
+	assert.Contains(t, status.Error.Title, "StatefulSet 'service1' (id 7)")
+	assert.Equal(t, "service1", (*status.Metrics)[0].Metric["k8s.statefulset"])
+}
+
+func TestPodStatusCheck(t *testing.T) {
+	var ids []string
+	for _, a := range NewWorkloadStatusCheckActions() {
+		ids = append(ids, a.Describe().Id)
+	}
+	assert.Contains(t, ids, "com.steadybit.extension_stackstate.pod.check")
+
+	podAction := &ServiceStatusCheckAction{kind: podKind}
+	description := podAction.Describe()
+	assert.Equal(t, podTargetType, description.TargetSelection.TargetType)
+	assert.Equal(t, `k8s.cluster-name="" AND k8s.namespace="" AND k8s.pod.name=""`, (*description.TargetSelection.SelectionTemplates)[0].Query)
+}
+
+func TestPodOwnerPrefix(t *testing.T) {
+	assert.Equal(t, "checkout-7d9f8b6c4-", podOwnerPrefix("checkout-7d9f8b6c4-x2k9p"))
+	assert.Equal(t, "checkout-api-5c8d7f9b6d-", podOwnerPrefix("checkout-api-5c8d7f9b6d-tq2lw"))
+	assert.Empty(t, podOwnerPrefix("node-agent-5kq2z"))
+	assert.Empty(t, podOwnerPrefix("postgres-0"))
+	assert.Empty(t, podOwnerPrefix("postgres-exporter-x2k9p"))
+	assert.Empty(t, podOwnerPrefix("standalone"))
+}
+
+func TestPodStatusCheckFollowsReplacementPod(t *testing.T) {
+	pod := func(id int, name string) Component {
+		return Component{
+			Id:          id,
+			Name:        name,
+			State:       State{HealthState: "CLEAR"},
+			Identifiers: []string{"urn:kubernetes:/prod:shop:pod/" + name},
+		}
+	}
+	ownerQuery := `(type = "pod" AND name = "checkout-7d9f8b6c4-*" AND label = "cluster-name:prod" AND label = "namespace:shop")`
+	action := &ServiceStatusCheckAction{kind: podKind}
+	state := action.NewEmptyState()
+	_, err := action.Prepare(context.TODO(), &state, action_kit_api.PrepareActionRequestBody{
+		Config: map[string]any{"duration": float64(60_000), "expectedStatus": []any{"CLEAR"}},
+		Target: &action_kit_api.Target{Attributes: map[string][]string{
+			"stackstate.component.id":  {"1"},
+			"stackstate.component.urn": {"urn:kubernetes:/prod:shop:pod/checkout-7d9f8b6c4-x2k9p"},
+			"k8s.pod.name":             {"checkout-7d9f8b6c4-x2k9p"},
+			"k8s.cluster-name":         {"prod"},
+			"k8s.namespace":            {"shop"},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "checkout-7d9f8b6c4-", state.OwnerPrefix)
+
+	mockedApi := new(getSnapshotApiMock)
+	mockedApi.On("QuerySnapshots", mock.Anything, ownerQuery).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{
+		ViewSnapshotResponse: ViewSnapshotResponse{Components: []Component{pod(1, "checkout-7d9f8b6c4-x2k9p"), pod(2, "checkout-7d9f8b6c4-bbbbb")}},
+	}, nil).Once()
+	StartStatusCheck(context.TODO(), &state, mockedApi)
+	assert.Equal(t, []string{"2"}, state.Siblings)
+
+	// The pod is killed mid-step, its replacement gets a new id and name next to the untouched sibling.
+	mockedApi.On("GetServiceSnapshot", mock.Anything, "1").Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+	mockedApi.On("QuerySnapshots", mock.Anything, `(identifier = "urn:kubernetes:/prod:shop:pod/checkout-7d9f8b6c4-x2k9p")`).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+	mockedApi.On("QuerySnapshots", mock.Anything, `(type = "pod" AND name = "checkout-7d9f8b6c4-x2k9p" AND label = "cluster-name:prod" AND label = "namespace:shop")`).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+	mockedApi.On("QuerySnapshots", mock.Anything, ownerQuery).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{
+		ViewSnapshotResponse: ViewSnapshotResponse{Components: []Component{pod(2, "checkout-7d9f8b6c4-bbbbb"), pod(3, "checkout-7d9f8b6c4-ccccc")}},
+	}, nil)
+
+	status, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+
+	require.NoError(t, err)
+	assert.Nil(t, status.Error)
+	assert.Equal(t, "3", state.ServiceId)
+	assert.Equal(t, "checkout-7d9f8b6c4-ccccc", state.ServiceName)
+	assert.Equal(t, "urn:kubernetes:/prod:shop:pod/checkout-7d9f8b6c4-ccccc", state.Urn)
+	assert.Equal(t, "checkout-7d9f8b6c4-ccccc", (*status.Metrics)[0].Metric["k8s.pod.name"])
+}
+
+func TestPodStatusCheckIgnoresAmbiguousReplacement(t *testing.T) {
+	state := serviceCheckState(statusCheckModeAllTheTime)
+	state.TargetType = podTargetType
+	state.Namespace = "shop"
+	state.OwnerPrefix = "test-7d9f8b6c4-"
+	mockedApi := new(getSnapshotApiMock)
+	mockedApi.On("GetServiceSnapshot", mock.Anything, "123").Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+	mockedApi.On("QuerySnapshots", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{
+		ViewSnapshotResponse: ViewSnapshotResponse{Components: []Component{{Id: 4, Name: "test-7d9f8b6c4-bbbbb"}, {Id: 5, Name: "test-7d9f8b6c4-ccccc"}}},
+	}, nil)
+
+	_, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+
+	require.Error(t, err)
+	assert.Equal(t, "123", state.ServiceId)
+}
+
+func TestPodStatusCheckIgnoresPodsOfOtherOwners(t *testing.T) {
+	state := serviceCheckState(statusCheckModeAllTheTime)
+	state.TargetType = podTargetType
+	state.Namespace = "shop"
+	state.ServiceName = "checkout-7d9f8b6c4-aaaaa"
+	state.OwnerPrefix = "checkout-7d9f8b6c4-"
+	mockedApi := new(getSnapshotApiMock)
+	mockedApi.On("GetServiceSnapshot", mock.Anything, "123").Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+	// The owner query's name pattern also matches the pod of the ReplicaSet of another deployment.
+	mockedApi.On("QuerySnapshots", mock.Anything, `(type = "pod" AND name = "checkout-7d9f8b6c4-*" AND label = "cluster-name:test-cluster" AND label = "namespace:shop")`).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{
+		ViewSnapshotResponse: ViewSnapshotResponse{Components: []Component{{Id: 4, Name: "checkout-7d9f8b6c4-worker-6b7c8d9f5c-x2k9p"}}},
+	}, nil)
+	mockedApi.On("QuerySnapshots", mock.Anything, mock.Anything).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+
+	_, err := MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+
+	require.Error(t, err)
+	assert.Equal(t, "123", state.ServiceId)
+}
+
+func TestPodStatusCheckDoesNotFollowStatefulSetPods(t *testing.T) {
+	action := &ServiceStatusCheckAction{kind: podKind}
+	state := action.NewEmptyState()
+	_, err := action.Prepare(context.TODO(), &state, action_kit_api.PrepareActionRequestBody{
+		Config: map[string]any{"duration": float64(60_000), "expectedStatus": []any{"CLEAR"}},
+		Target: &action_kit_api.Target{Attributes: map[string][]string{
+			"stackstate.component.id":  {"1"},
+			"stackstate.component.urn": {"urn:kubernetes:/prod:shop:pod/postgres-0"},
+			"k8s.pod.name":             {"postgres-0"},
+			"k8s.cluster-name":         {"prod"},
+			"k8s.namespace":            {"shop"},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, state.OwnerPrefix)
+
+	// Only the lookups by URN and name are made, the pods of "postgres-exporter" aren't taken for a replacement.
+	mockedApi := new(getSnapshotApiMock)
+	mockedApi.On("GetServiceSnapshot", mock.Anything, "1").Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+	mockedApi.On("QuerySnapshots", mock.Anything, `(identifier = "urn:kubernetes:/prod:shop:pod/postgres-0")`).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+	mockedApi.On("QuerySnapshots", mock.Anything, `(type = "pod" AND name = "postgres-0" AND label = "cluster-name:prod" AND label = "namespace:shop")`).Return(apiResponseWithStatus(200), ViewSnapshotResponseWrapper{}, nil)
+
+	_, err = MonitorStatusCheckStatus(context.TODO(), &state, mockedApi)
+
+	require.Error(t, err)
+	assert.Equal(t, "1", state.ServiceId)
+	mockedApi.AssertNumberOfCalls(t, "QuerySnapshots", 2)
+}
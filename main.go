@@ -36,7 +36,24 @@ func main() {
 	initStackStateHttpClient()
 
 	discovery_kit_sdk.Register(extservice.NewServiceDiscovery())
+	for _, discovery := range extservice.NewWorkloadDiscoveries() {
+		discovery_kit_sdk.Register(discovery)
+	}
+	for _, discovery := range extservice.NewCustomDiscoveries() {
+		discovery_kit_sdk.Register(discovery)
+	}
+	discovery_kit_sdk.Register(extservice.NewMonitorDiscovery())
 	action_kit_sdk.RegisterAction(extservice.NewServiceStatusCheckAction())
+	for _, action := range extservice.NewWorkloadStatusCheckActions() {
+		action_kit_sdk.RegisterAction(action)
+	}
+	for _, action := range extservice.NewCustomStatusCheckActions() {
+		action_kit_sdk.RegisterAction(action)
+	}
+	action_kit_sdk.RegisterAction(extservice.NewMonitorStatusCheckAction())
+	for _, action := range extservice.NewBlastRadiusCheckActions() {
+		action_kit_sdk.RegisterAction(action)
+	}
 
 	exthttp.RegisterRevisionedHandler("/", getExtensionList)
 
@@ -56,6 +73,7 @@ func initStackStateHttpClient() {
 	client.SetBaseURL(config.Config.ApiBaseUrl)
 	client.SetHeader("X-API-Key", config.Config.ServiceToken)
 	client.SetHeader("Content-Type", "application/json")
+	extservice.ConfigureResilience(client)
 	extservice.Client = &extservice.StackStateHttpClient{
 		Client: client,
 	}